@@ -0,0 +1,283 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lab01/taskmanager"
+)
+
+// APIResponse is the envelope of every response body
+type APIResponse struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// CreateTaskRequest is the body of POST /api/tasks
+type CreateTaskRequest struct {
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	DueDate     *time.Time            `json:"due_date,omitempty"`
+	Priority    *taskmanager.Priority `json:"priority,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	ParentID    int                   `json:"parent_id,omitempty"`
+	BlockedBy   []int                 `json:"blocked_by,omitempty"`
+}
+
+// UpdateTaskRequest is the body of PUT /api/tasks/{id}, title, description and
+// done replace the current values while omitted optional fields are kept. A
+// null or empty due_date clears the due date.
+type UpdateTaskRequest struct {
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Done        bool                  `json:"done"`
+	DueDate     OptionalTime          `json:"due_date"`
+	Priority    *taskmanager.Priority `json:"priority,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	ParentID    *int                  `json:"parent_id,omitempty"`
+	BlockedBy   []int                 `json:"blocked_by,omitempty"`
+}
+
+// OptionalTime is a time field of a request that may be omitted, Set tells
+// whether it was present and Time is nil when it was null or empty
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON accepts a time, null or an empty string
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set, o.Time = true, nil
+	if string(data) == "null" || string(data) == `""` {
+		return nil
+	}
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	o.Time = &t
+	return nil
+}
+
+// MarshalJSON encodes the time, or null when it is not set
+func (o OptionalTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Time)
+}
+
+// options converts the optional fields of the request into task options
+func (r CreateTaskRequest) options() []taskmanager.TaskOption {
+	update := UpdateTaskRequest{DueDate: OptionalTime{Set: r.DueDate != nil, Time: r.DueDate}, Priority: r.Priority, Tags: r.Tags, BlockedBy: r.BlockedBy}
+	if r.ParentID != 0 {
+		update.ParentID = &r.ParentID
+	}
+	return update.options()
+}
+
+// options converts the optional fields of the request into task options
+func (r UpdateTaskRequest) options() []taskmanager.TaskOption {
+	var opts []taskmanager.TaskOption
+	switch {
+	case r.DueDate.Time != nil:
+		opts = append(opts, taskmanager.WithDueDate(*r.DueDate.Time))
+	case r.DueDate.Set:
+		opts = append(opts, taskmanager.WithoutDueDate())
+	}
+	if r.Priority != nil {
+		opts = append(opts, taskmanager.WithPriority(*r.Priority))
+	}
+	if r.Tags != nil {
+		opts = append(opts, taskmanager.WithTags(r.Tags...))
+	}
+	if r.ParentID != nil {
+		opts = append(opts, taskmanager.WithParent(*r.ParentID))
+	}
+	if r.BlockedBy != nil {
+		opts = append(opts, taskmanager.WithBlockedBy(r.BlockedBy...))
+	}
+	return opts
+}
+
+// Handler serves the task API over a TaskManager
+type Handler struct {
+	tm *taskmanager.TaskManager
+}
+
+// NewHandler creates a new handler instance
+func NewHandler(tm *taskmanager.TaskManager) *Handler {
+	return &Handler{tm: tm}
+}
+
+// SetupRoutes configures all API routes
+func (h *Handler) SetupRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", h.ListTasks)
+	mux.HandleFunc("POST /api/tasks", h.CreateTask)
+	mux.HandleFunc("GET /api/tasks/{id}", h.GetTask)
+	mux.HandleFunc("PUT /api/tasks/{id}", h.UpdateTask)
+	mux.HandleFunc("DELETE /api/tasks/{id}", h.DeleteTask)
+	return mux
+}
+
+// ListTasks handles GET /api/tasks, the optional done query parameter filters
+// by status and q runs a full-text search instead
+func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	var done *bool
+	if value := r.URL.Query().Get("done"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid done parameter")
+			return
+		}
+		done = &b
+	}
+
+	if query := r.URL.Query().Get("q"); query != "" {
+		tasks := []taskmanager.Task{}
+		for _, result := range h.tm.Search(query) {
+			if done == nil || result.Task.Done == *done {
+				tasks = append(tasks, result.Task)
+			}
+		}
+		h.writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: tasks})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: h.tm.ListTasks(done)})
+}
+
+// CreateTask handles POST /api/tasks
+func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	var req CreateTaskRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	task, err := h.tm.AddTask(req.Title, req.Description, req.options()...)
+	if err != nil {
+		h.writeTaskError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, APIResponse{Success: true, Data: task})
+}
+
+// GetTask handles GET /api/tasks/{id}
+func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := h.tm.GetTask(id)
+	if err != nil {
+		h.writeTaskError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: task})
+}
+
+// UpdateTask handles PUT /api/tasks/{id}
+func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateTaskRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if err := h.tm.UpdateTask(id, req.Title, req.Description, req.Done, req.options()...); err != nil {
+		h.writeTaskError(w, err)
+		return
+	}
+	task, err := h.tm.GetTask(id)
+	if err != nil {
+		h.writeTaskError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: task})
+}
+
+// DeleteTask handles DELETE /api/tasks/{id}, cascade=true also removes subtasks
+func (h *Handler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var opts []taskmanager.DeleteOption
+	if cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade")); cascade {
+		opts = append(opts, taskmanager.WithCascade())
+	}
+	if err := h.tm.DeleteTask(id, opts...); err != nil {
+		h.writeTaskError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper function to parse the task ID from the URL path
+func (h *Handler) parseID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid task ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// Helper function to map task manager errors to status codes
+func (h *Handler) writeTaskError(w http.ResponseWriter, err error) {
+	h.writeError(w, statusFor(err), err.Error())
+}
+
+// statusFor returns the HTTP status code for an error of the task manager
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, taskmanager.ErrInvalidReference):
+		return http.StatusBadRequest
+	case errors.Is(err, taskmanager.ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, taskmanager.ErrEmptyTitle),
+		errors.Is(err, taskmanager.ErrInvalidPriority),
+		errors.Is(err, taskmanager.ErrInvalidRecurrence),
+		errors.Is(err, taskmanager.ErrDependencyCycle):
+		return http.StatusBadRequest
+	case errors.Is(err, taskmanager.ErrBlocked),
+		errors.Is(err, taskmanager.ErrHasSubtasks):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper function to write JSON responses
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
+
+// Helper function to write error responses
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, APIResponse{Success: false, Error: message})
+}
+
+// Helper function to parse JSON request body
+func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("decode request body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lab01/taskmanager"
+)
+
+func setupTestHandler() (*taskmanager.TaskManager, http.Handler) {
+	tm := taskmanager.NewTaskManager()
+	return tm, NewHandler(tm).SetupRoutes()
+}
+
+func doRequest(t *testing.T, router http.Handler, method, path, body string) (*httptest.ResponseRecorder, APIResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var response APIResponse
+	if rr.Code != http.StatusNoContent {
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+	}
+	return rr, response
+}
+
+func TestTaskCRUD(t *testing.T) {
+	tm, router := setupTestHandler()
+
+	rr, response := doRequest(t, router, "POST", "/api/tasks", `{"title": "Write docs", "priority": 3, "tags": ["Work"]}`)
+	if rr.Code != http.StatusCreated || !response.Success {
+		t.Fatalf("Expected status %v, got %v: %+v", http.StatusCreated, rr.Code, response)
+	}
+	created, err := tm.GetTask(1)
+	if err != nil || created.Priority != taskmanager.PriorityHigh || !created.HasTag("work") {
+		t.Fatalf("task not created as requested: %+v, %v", created, err)
+	}
+
+	rr, response = doRequest(t, router, "GET", "/api/tasks/1", "")
+	if rr.Code != http.StatusOK || response.Data.(map[string]interface{})["title"] != "Write docs" {
+		t.Errorf("unexpected GET response %v: %+v", rr.Code, response)
+	}
+
+	rr, _ = doRequest(t, router, "PUT", "/api/tasks/1", `{"title": "Write docs", "description": "API section", "done": true}`)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+	if updated, _ := tm.GetTask(1); !updated.Done || updated.Description != "API section" || updated.Priority != taskmanager.PriorityHigh {
+		t.Errorf("task not updated as requested: %+v", updated)
+	}
+
+	rr, _ = doRequest(t, router, "DELETE", "/api/tasks/1", "")
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %v, got %v", http.StatusNoContent, rr.Code)
+	}
+	if _, err := tm.GetTask(1); err != taskmanager.ErrTaskNotFound {
+		t.Errorf("task not deleted: %v", err)
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	tm, router := setupTestHandler()
+	tm.AddTask("Buy milk", "")
+	report, _ := tm.AddTask("Quarterly report", "")
+	tm.AddTask("Report bug", "")
+	tm.UpdateTask(report.ID, report.Title, "", true)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/tasks", 3},
+		{"/api/tasks?done=true", 1},
+		{"/api/tasks?done=false", 2},
+		{"/api/tasks?q=report", 2},
+		{"/api/tasks?q=report&done=false", 1},
+		{"/api/tasks?q=nothing", 0},
+	}
+	for _, tt := range tests {
+		rr, response := doRequest(t, router, "GET", tt.path, "")
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status %v, got %v", tt.path, http.StatusOK, rr.Code)
+			continue
+		}
+		if got := len(response.Data.([]interface{})); got != tt.want {
+			t.Errorf("%s: got %d tasks, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTaskErrors(t *testing.T) {
+	tm, router := setupTestHandler()
+	parent, _ := tm.AddTask("Parent", "")
+	tm.AddTask("Child", "", taskmanager.WithParent(parent.ID))
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/tasks", `{"title": ""}`, http.StatusBadRequest},
+		{"POST", "/api/tasks", `{"title": "Bad", "priority": 7}`, http.StatusBadRequest},
+		{"POST", "/api/tasks", `not json`, http.StatusBadRequest},
+		{"POST", "/api/tasks", `{"title": "Typo", "titel": "x"}`, http.StatusBadRequest},
+		{"GET", "/api/tasks/99", "", http.StatusNotFound},
+		{"GET", "/api/tasks/abc", "", http.StatusBadRequest},
+		{"GET", "/api/tasks?done=maybe", "", http.StatusBadRequest},
+		{"PUT", "/api/tasks/99", `{"title": "Missing"}`, http.StatusNotFound},
+		{"PUT", "/api/tasks/1", `{"title": ""}`, http.StatusBadRequest},
+		{"POST", "/api/tasks", `{"title": "Orphan", "parent_id": 99}`, http.StatusBadRequest},
+		{"PUT", "/api/tasks/2", `{"title": "Child", "blocked_by": [99]}`, http.StatusBadRequest},
+		{"DELETE", "/api/tasks/99", "", http.StatusNotFound},
+		{"DELETE", "/api/tasks/1", "", http.StatusConflict},
+		{"PATCH", "/api/tasks/1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.method, tt.path), func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %v, got %v: %s", tt.want, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	rr, _ := doRequest(t, router, "DELETE", "/api/tasks/1?cascade=true", "")
+	if rr.Code != http.StatusNoContent || len(tm.ListTasks(nil)) != 0 {
+		t.Errorf("cascading delete failed with status %v", rr.Code)
+	}
+}
+
+func TestUpdateDueDate(t *testing.T) {
+	tm, router := setupTestHandler()
+	doRequest(t, router, "POST", "/api/tasks", `{"title": "Taxes", "due_date": "2026-04-15T00:00:00Z"}`)
+
+	tests := []struct {
+		body    string
+		wantDue bool
+	}{
+		{`{"title": "Taxes"}`, true},
+		{`{"title": "Taxes", "due_date": null}`, false},
+		{`{"title": "Taxes", "due_date": "2026-05-01T00:00:00Z"}`, true},
+		{`{"title": "Taxes", "due_date": ""}`, false},
+	}
+	for _, tt := range tests {
+		rr, _ := doRequest(t, router, "PUT", "/api/tasks/1", tt.body)
+		task, _ := tm.GetTask(1)
+		if rr.Code != http.StatusOK || (task.DueDate != nil) != tt.wantDue {
+			t.Errorf("%s: got status %v and due date %v", tt.body, rr.Code, task.DueDate)
+		}
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	wrapped := fmt.Errorf("parent 5: %w", taskmanager.ErrTaskNotFound)
+	if got := statusFor(wrapped); got != http.StatusNotFound {
+		t.Errorf("statusFor(wrapped ErrTaskNotFound) = %d", got)
+	}
+	reference := fmt.Errorf("%w: blocker 5: %w", taskmanager.ErrInvalidReference, taskmanager.ErrTaskNotFound)
+	if got := statusFor(reference); got != http.StatusBadRequest {
+		t.Errorf("statusFor(ErrInvalidReference) = %d", got)
+	}
+	if got := statusFor(errors.New("disk full")); got != http.StatusInternalServerError {
+		t.Errorf("statusFor(unknown) = %d", got)
+	}
+}
@@ -0,0 +1,413 @@
+// Command tasks manages a task list stored in a JSON file and can serve it
+// over the HTTP API of package api.
+//
+// Usage:
+//
+//	tasks [-file path] [-json] <command> [flags] [args]
+//
+// Commands:
+//
+//	add [-d text] [-due date] [-p priority] [-tags a,b] [-parent ID] TITLE
+//	list [-done | -open] [-tag tag] [-q query]
+//	done ID...
+//	rm [-cascade] ID
+//	edit [-title text] [-d text] [-due date] [-p priority] [-tags a,b] [-done | -open] ID
+//	serve [-addr :8080]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"lab01/api"
+	"lab01/taskmanager"
+)
+
+const usage = `usage: tasks [-file path] [-json] <command> [flags] [args]
+
+commands:
+  add    add a task
+  list   list tasks
+  done   mark tasks as done
+  rm     delete a task
+  edit   change a task
+  serve  serve the HTTP API
+`
+
+// errUsage is returned for malformed command lines
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "tasks:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// cli holds the state shared by all commands
+type cli struct {
+	tm     *taskmanager.TaskManager
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run executes the command line args, writing results to stdout and usage
+// errors to stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	global := flag.NewFlagSet("tasks", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	file := global.String("file", envOr("TASKS_FILE", "tasks.json"), "task file")
+	asJSON := global.Bool("json", false, "print JSON instead of a table")
+	if err := global.Parse(args); err != nil {
+		return errUsage
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return errUsage
+	}
+
+	store, err := taskmanager.NewFileStore(*file)
+	if err != nil {
+		return err
+	}
+	tm, err := taskmanager.NewTaskManagerWithStore(store)
+	if err != nil {
+		return err
+	}
+	c := &cli{tm: tm, json: *asJSON, stdout: stdout, stderr: stderr}
+
+	name, args := global.Arg(0), global.Args()[1:]
+	switch name {
+	case "add":
+		return c.add(args)
+	case "list":
+		return c.list(args)
+	case "done":
+		return c.done(args)
+	case "rm":
+		return c.remove(args)
+	case "edit":
+		return c.edit(args)
+	case "serve":
+		return c.serve(args)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		global.Usage()
+		return errUsage
+	}
+}
+
+func (c *cli) add(args []string) error {
+	fs := c.flagSet("add", "[-d text] [-due date] [-p priority] [-tags a,b] [-parent ID] TITLE")
+	description := fs.String("d", "", "description")
+	parent := fs.Int("parent", 0, "parent task ID")
+	var opts taskOptions
+	opts.register(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if *parent != 0 {
+		opts.list = append(opts.list, taskmanager.WithParent(*parent))
+	}
+
+	task, err := c.tm.AddTask(strings.Join(positional, " "), *description, opts.list...)
+	if err != nil {
+		return err
+	}
+	return c.print(task)
+}
+
+func (c *cli) list(args []string) error {
+	fs := c.flagSet("list", "[-done | -open] [-tag tag] [-q query]")
+	onlyDone := fs.Bool("done", false, "only done tasks")
+	onlyOpen := fs.Bool("open", false, "only open tasks")
+	tag := fs.String("tag", "", "only tasks with this tag")
+	query := fs.String("q", "", "full-text search, best matches first")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	if *onlyDone && *onlyOpen {
+		fmt.Fprintln(c.stderr, "-done and -open are mutually exclusive")
+		return errUsage
+	}
+
+	filter := taskmanager.TaskFilter{Tag: *tag}
+	if *onlyDone || *onlyOpen {
+		filter.Done = onlyDone
+	}
+	if *query == "" {
+		return c.print(c.tm.FilterTasks(filter)...)
+	}
+
+	var tasks []taskmanager.Task
+	for _, result := range c.tm.Search(*query) {
+		if filter.Done != nil && result.Task.Done != *filter.Done {
+			continue
+		}
+		if filter.Tag != "" && !result.Task.HasTag(filter.Tag) {
+			continue
+		}
+		tasks = append(tasks, result.Task)
+	}
+	return c.print(tasks...)
+}
+
+func (c *cli) done(args []string) error {
+	fs := c.flagSet("done", "ID...")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(fs, positional)
+	if err != nil {
+		return err
+	}
+
+	tasks := make([]taskmanager.Task, 0, len(ids))
+	for _, id := range ids {
+		task, err := c.tm.GetTask(id)
+		if err != nil {
+			return fmt.Errorf("task %d: %w", id, err)
+		}
+		if err := c.tm.UpdateTask(id, task.Title, task.Description, true); err != nil {
+			return fmt.Errorf("task %d: %w", id, err)
+		}
+		task, _ = c.tm.GetTask(id)
+		tasks = append(tasks, task)
+	}
+	return c.print(tasks...)
+}
+
+func (c *cli) remove(args []string) error {
+	fs := c.flagSet("rm", "[-cascade] ID")
+	cascade := fs.Bool("cascade", false, "also delete subtasks")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(fs, positional)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var opts []taskmanager.DeleteOption
+	if *cascade {
+		opts = append(opts, taskmanager.WithCascade())
+	}
+	if err := c.tm.DeleteTask(ids[0], opts...); err != nil {
+		return fmt.Errorf("task %d: %w", ids[0], err)
+	}
+	return nil
+}
+
+func (c *cli) edit(args []string) error {
+	fs := c.flagSet("edit", "[-title text] [-d text] [-due date] [-p priority] [-tags a,b] [-done | -open] ID")
+	title := fs.String("title", "", "new title")
+	description := fs.String("d", "", "new description")
+	markDone := fs.Bool("done", false, "mark as done")
+	markOpen := fs.Bool("open", false, "mark as open")
+	var opts taskOptions
+	opts.register(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(fs, positional)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 || (*markDone && *markOpen) {
+		fs.Usage()
+		return errUsage
+	}
+
+	id := ids[0]
+	task, err := c.tm.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("task %d: %w", id, err)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			task.Title = *title
+		case "d":
+			task.Description = *description
+		case "done":
+			task.Done = true
+		case "open":
+			task.Done = false
+		}
+	})
+	if err := c.tm.UpdateTask(id, task.Title, task.Description, task.Done, opts.list...); err != nil {
+		return fmt.Errorf("task %d: %w", id, err)
+	}
+	task, _ = c.tm.GetTask(id)
+	return c.print(task)
+}
+
+func (c *cli) serve(args []string) error {
+	fs := c.flagSet("serve", "[-addr :8080]")
+	addr := fs.String("addr", ":8080", "listen address")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:         *addr,
+		Handler:      api.NewHandler(c.tm).SetupRoutes(),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	log.Printf("serving the task API on %s", *addr)
+	return server.ListenAndServe()
+}
+
+// print writes tasks as a table or, with -json, as a JSON array
+func (c *cli) print(tasks ...taskmanager.Task) error {
+	if c.json {
+		if tasks == nil {
+			tasks = []taskmanager.Task{}
+		}
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tasks)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDONE\tPRIORITY\tDUE\tTITLE\tTAGS")
+	for _, task := range tasks {
+		done, due := " ", "-"
+		if task.Done {
+			done = "x"
+		}
+		if task.DueDate != nil {
+			due = task.DueDate.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", task.ID, done, task.Priority, due, task.Title, strings.Join(task.Tags, ","))
+	}
+	return w.Flush()
+}
+
+// flagSet creates the flag set of a command
+func (c *cli) flagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: tasks %s %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// taskOptions collects the flags shared by add and edit as task options
+type taskOptions struct {
+	list []taskmanager.TaskOption
+}
+
+func (o *taskOptions) register(fs *flag.FlagSet) {
+	fs.Func("due", "due date, YYYY-MM-DD or RFC 3339, empty to clear", func(s string) error {
+		if s == "" {
+			o.list = append(o.list, taskmanager.WithoutDueDate())
+			return nil
+		}
+		due, err := parseDate(s)
+		if err != nil {
+			return err
+		}
+		o.list = append(o.list, taskmanager.WithDueDate(due))
+		return nil
+	})
+	fs.Func("p", "priority: none, low, medium or high", func(s string) error {
+		p, err := taskmanager.ParsePriority(s)
+		if err != nil {
+			return err
+		}
+		o.list = append(o.list, taskmanager.WithPriority(p))
+		return nil
+	})
+	fs.Func("tags", "comma separated tags, empty to clear", func(s string) error {
+		var tags []string
+		if s != "" {
+			tags = strings.Split(s, ",")
+		}
+		o.list = append(o.list, taskmanager.WithTags(tags...))
+		return nil
+	})
+}
+
+// parseInterspersed parses flags that may appear between positional
+// arguments and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseIDs converts positional arguments into task IDs
+func parseIDs(fs *flag.FlagSet, args []string) ([]int, error) {
+	if len(args) == 0 {
+		fs.Usage()
+		return nil, errUsage
+	}
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid task ID %q", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// parseDate accepts a calendar date in local time or an RFC 3339 timestamp
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
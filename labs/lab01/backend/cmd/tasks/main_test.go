@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lab01/taskmanager"
+)
+
+// runTasks runs the CLI against file and returns what it printed
+func runTasks(t *testing.T, file string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(append([]string{"-file", file}, args...), &stdout, &stderr)
+	return stdout.String() + stderr.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tasks.json")
+
+	out, err := runTasks(t, file, "add", "-p", "high", "Write", "report", "-tags", "work,Q3", "-due", "2025-07-01")
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Write report") || !strings.Contains(out, "2025-07-01") || !strings.Contains(out, "high") {
+		t.Errorf("unexpected add output:\n%s", out)
+	}
+	runTasks(t, file, "add", "Buy milk")
+
+	if out, err := runTasks(t, file, "done", "2"); err != nil {
+		t.Fatalf("done failed: %v\n%s", err, out)
+	}
+	out, _ = runTasks(t, file, "list", "-done")
+	if !strings.Contains(out, "Buy milk") || strings.Contains(out, "Write report") {
+		t.Errorf("list -done printed:\n%s", out)
+	}
+	out, _ = runTasks(t, file, "list", "-q", "rep")
+	if !strings.Contains(out, "Write report") || strings.Contains(out, "Buy milk") {
+		t.Errorf("list -q printed:\n%s", out)
+	}
+
+	if out, err := runTasks(t, file, "edit", "1", "-title", "Write final report", "-p", "low"); err != nil {
+		t.Fatalf("edit failed: %v\n%s", err, out)
+	}
+	out, _ = runTasks(t, file, "-json", "list", "-open")
+	var tasks []taskmanager.Task
+	if err := json.Unmarshal([]byte(out), &tasks); err != nil {
+		t.Fatalf("list -json printed invalid JSON: %v\n%s", err, out)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Write final report" || tasks[0].Priority != taskmanager.PriorityLow ||
+		!tasks[0].HasTag("q3") || tasks[0].DueDate == nil {
+		t.Errorf("unexpected tasks after edit: %+v", tasks)
+	}
+
+	if out, err := runTasks(t, file, "rm", "1"); err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, out)
+	}
+	out, _ = runTasks(t, file, "-json", "list")
+	tasks = nil
+	if err := json.Unmarshal([]byte(out), &tasks); err != nil || len(tasks) != 1 || tasks[0].ID != 2 {
+		t.Errorf("unexpected tasks after rm: %v\n%s", err, out)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tasks.json")
+	runTasks(t, file, "add", "Parent")
+	runTasks(t, file, "add", "-parent", "1", "Child")
+
+	tests := []struct {
+		args []string
+		want error
+	}{
+		{nil, errUsage},
+		{[]string{"frobnicate"}, errUsage},
+		{[]string{"add"}, errUsage},
+		{[]string{"add", "-p", "urgent", "Task"}, errUsage},
+		{[]string{"done"}, errUsage},
+		{[]string{"done", "99"}, taskmanager.ErrTaskNotFound},
+		{[]string{"rm", "1"}, taskmanager.ErrHasSubtasks},
+		{[]string{"list", "-done", "-open"}, errUsage},
+		{[]string{"edit", "1", "-title", ""}, taskmanager.ErrEmptyTitle},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			if _, err := runTasks(t, file, tt.args...); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := runTasks(t, file, "rm", "-cascade", "1"); err != nil {
+		t.Errorf("rm -cascade failed: %v", err)
+	}
+}
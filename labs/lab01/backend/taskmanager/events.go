@@ -0,0 +1,153 @@
+package taskmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to a task
+type EventType int
+
+// Supported event types
+const (
+	EventCreated EventType = iota + 1
+	EventUpdated
+	EventDeleted
+)
+
+// String returns the lower-case name of the event type
+func (e EventType) String() string {
+	switch e {
+	case EventCreated:
+		return "created"
+	case EventUpdated:
+		return "updated"
+	case EventDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// TaskEvent describes a single change made through a TaskManager
+type TaskEvent struct {
+	Type EventType
+	// Task is the task after the change, or the removed task for EventDeleted
+	Task Task
+	// Previous is the task before the change, set only for EventUpdated
+	Previous Task
+	Time     time.Time
+}
+
+// MaxPendingEvents is the number of events queued for a subscriber that does
+// not keep up, the subscription is cancelled when one more arrives
+const MaxPendingEvents = 1000
+
+// Subscribe returns a channel receiving every change made after the call, in
+// the order the changes were applied, and a function that cancels the
+// subscription and closes the channel. Events are queued per subscriber, so a
+// slow reader never blocks the TaskManager or other subscribers. A reader
+// falling more than MaxPendingEvents behind has its channel closed instead,
+// it missed events and has to subscribe again.
+func (tm *TaskManager) Subscribe() (<-chan TaskEvent, func()) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.events.subscribe()
+}
+
+// eventHub fans events out to subscribers, the zero value is ready to use and
+// callers serialize access with the TaskManager lock
+type eventHub struct {
+	subscribers map[*subscriber]struct{}
+}
+
+func (h *eventHub) subscribe() (<-chan TaskEvent, func()) {
+	if h.subscribers == nil {
+		h.subscribers = make(map[*subscriber]struct{})
+	}
+	sub := newSubscriber()
+	h.subscribers[sub] = struct{}{}
+	go sub.run()
+	return sub.ch, sub.cancel
+}
+
+func (h *eventHub) publish(event TaskEvent) {
+	for sub := range h.subscribers {
+		if sub.closed() {
+			delete(h.subscribers, sub)
+			continue
+		}
+		sub.push(event)
+	}
+}
+
+// subscriber buffers events in a queue of up to MaxPendingEvents and forwards them to ch
+type subscriber struct {
+	ch     chan TaskEvent
+	mu     sync.Mutex
+	queue  []TaskEvent
+	signal chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newSubscriber() *subscriber {
+	return &subscriber{
+		ch:     make(chan TaskEvent),
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (s *subscriber) push(event TaskEvent) {
+	s.mu.Lock()
+	if len(s.queue) >= MaxPendingEvents {
+		s.mu.Unlock()
+		s.cancel()
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) cancel() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *subscriber) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run delivers queued events until the subscription is cancelled
+func (s *subscriber) run() {
+	defer close(s.ch)
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.signal:
+		}
+
+		s.mu.Lock()
+		pending := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, event := range pending {
+			select {
+			case s.ch <- event:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
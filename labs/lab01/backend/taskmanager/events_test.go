@@ -0,0 +1,166 @@
+package taskmanager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, ch <-chan TaskEvent) TaskEvent {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		if !ok {
+			t.Fatal("event channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return TaskEvent{}
+}
+
+func TestSubscribe(t *testing.T) {
+	tm := NewTaskManager()
+	events, cancel := tm.Subscribe()
+	defer cancel()
+
+	task, _ := tm.AddTask("Task", "Description")
+	tm.UpdateTask(task.ID, "Renamed", "Description", true)
+	tm.DeleteTask(task.ID)
+	// Failed mutations must not emit events
+	tm.DeleteTask(task.ID)
+	tm.AddTask("", "")
+
+	created := receiveEvent(t, events)
+	if created.Type != EventCreated || created.Task.Title != "Task" {
+		t.Errorf("unexpected created event: %+v", created)
+	}
+	updated := receiveEvent(t, events)
+	if updated.Type != EventUpdated || updated.Task.Title != "Renamed" || updated.Previous.Title != "Task" {
+		t.Errorf("unexpected updated event: %+v", updated)
+	}
+	deleted := receiveEvent(t, events)
+	if deleted.Type != EventDeleted || deleted.Task.ID != task.ID {
+		t.Errorf("unexpected deleted event: %+v", deleted)
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected extra event: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	tm := NewTaskManager()
+	events, cancel := tm.Subscribe()
+	cancel()
+	cancel() // cancelling twice is harmless
+
+	tm.AddTask("Task", "")
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("received event after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel was not closed after cancel")
+	}
+}
+
+func TestSlowSubscriberDoesNotBlock(t *testing.T) {
+	tm := NewTaskManager()
+	slow, cancelSlow := tm.Subscribe()
+	defer cancelSlow()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			tm.AddTask(fmt.Sprintf("Task %d", i), "")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddTask blocked on a subscriber that is not reading")
+	}
+
+	// The slow subscriber still gets every event in order
+	for i := 0; i < 1000; i++ {
+		event := receiveEvent(t, slow)
+		if event.Task.ID != i+1 {
+			t.Fatalf("event %d has task ID %d", i, event.Task.ID)
+		}
+	}
+}
+
+func TestSubscriberFallingBehindIsClosed(t *testing.T) {
+	tm := NewTaskManager()
+	events, cancel := tm.Subscribe()
+	defer cancel()
+
+	// the queue holds MaxPendingEvents, plus at most as many being delivered
+	const added = 2*MaxPendingEvents + 2
+	for i := 0; i < added; i++ {
+		tm.AddTask(fmt.Sprintf("Task %d", i), "")
+	}
+	received := 0
+	for {
+		select {
+		case _, ok := <-events:
+			if ok {
+				received++
+				continue
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel of a subscriber that fell behind was not closed")
+		}
+		break
+	}
+	if received >= added {
+		t.Errorf("expected events to be dropped, received all %d", received)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	tm := NewTaskManager()
+	events, cancel := tm.Subscribe()
+	defer cancel()
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				task, err := tm.AddTask(fmt.Sprintf("Task %d-%d", w, i), "")
+				if err != nil {
+					t.Errorf("AddTask failed: %v", err)
+					return
+				}
+				tm.UpdateTask(task.ID, task.Title, "", true)
+				tm.GetTask(task.ID)
+				tm.ListTasks(nil)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := len(tm.ListTasks(nil)); got != workers*perWorker {
+		t.Errorf("expected %d tasks, got %d", workers*perWorker, got)
+	}
+	seen := make(map[int]bool)
+	for i := 0; i < 2*workers*perWorker; i++ {
+		event := receiveEvent(t, events)
+		if event.Type == EventCreated {
+			if seen[event.Task.ID] {
+				t.Fatalf("duplicate ID %d", event.Task.ID)
+			}
+			seen[event.Task.ID] = true
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package taskmanager
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects the file format used by Export and Import
+type Format int
+
+// Supported formats
+const (
+	FormatJSON Format = iota + 1
+	FormatCSV
+	FormatICal
+)
+
+// String returns the lower-case name of the format
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatCSV:
+		return "csv"
+	case FormatICal:
+		return "ical"
+	}
+	return "unknown"
+}
+
+// ParseFormat returns the format with the given name or file extension, e.g. "csv" or "ics"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "ical", "ics", "icalendar":
+		return FormatICal, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// Export writes every task, ordered by ID, to w in the given format
+func (tm *TaskManager) Export(w io.Writer, format Format) error {
+	tasks := tm.ListTasks(nil)
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tasks)
+	case FormatCSV:
+		return writeCSV(w, tasks)
+	case FormatICal:
+		return writeICal(w, tasks, tm.now())
+	}
+	return fmt.Errorf("%w: %d", ErrUnknownFormat, format)
+}
+
+// ImportOptions changes how Import applies the input
+type ImportOptions struct {
+	// DryRun reports what would be imported without changing the manager
+	DryRun bool
+}
+
+// ImportConflict describes an input task whose ID is already taken by an existing task
+type ImportConflict struct {
+	ID       int
+	NewID    int
+	Existing Task
+	Incoming Task
+}
+
+// ImportError describes an input record that was rejected
+type ImportError struct {
+	// Record is the 1-based position of the task in the input
+	Record int
+	// ID is the task ID given in the input, zero if there was none
+	ID  int
+	Err error
+}
+
+func (e ImportError) Error() string {
+	if e.ID > 0 {
+		return fmt.Sprintf("record %d (id %d): %v", e.Record, e.ID, e.Err)
+	}
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReport summarizes the result of Import
+type ImportReport struct {
+	DryRun bool
+	// Imported lists the added tasks (or the tasks that would be added) with their final IDs
+	Imported []Task
+	// Remapped maps input IDs to the IDs assigned on import, only for IDs that changed
+	Remapped map[int]int
+	// Conflicts lists input tasks whose ID was already used by an existing task
+	Conflicts []ImportConflict
+	// Errors lists the rejected input records
+	Errors []ImportError
+}
+
+// Err joins all record errors, nil if every record was accepted
+func (r ImportReport) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// Import reads tasks in the given format from r and adds them to the manager.
+// Input IDs that are free and not below nextID are kept, other tasks get new
+// IDs and their parent and blocked-by links are rewritten to match. Records
+// that fail validation, link to tasks missing from the input, or are done
+// while blocked by an open task (ErrBlocked), are skipped and listed in the
+// report. The returned error is only set when the input
+// cannot be parsed or the store fails.
+func (tm *TaskManager) Import(r io.Reader, format Format, opts ImportOptions) (ImportReport, error) {
+	return tm.importTasks("", r, format, opts)
+}
+
+func (tm *TaskManager) importTasks(actor string, r io.Reader, format Format, opts ImportOptions) (ImportReport, error) {
+	var records []Task
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&records)
+	case FormatCSV:
+		records, err = readCSV(r)
+	case FormatICal:
+		records, err = readICal(r)
+	default:
+		err = fmt.Errorf("%w: %d", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	defer tm.commitLocked(OpImport, actor, 0)
+	return tm.importLocked(records, opts)
+}
+
+// importPlan tracks one accepted input record while IDs are assigned
+type importPlan struct {
+	record int
+	task   Task
+}
+
+func (tm *TaskManager) importLocked(records []Task, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{DryRun: opts.DryRun, Remapped: make(map[int]int)}
+	reject := func(record int, task Task, err error) {
+		id := max(task.ID, 0)
+		report.Errors = append(report.Errors, ImportError{Record: record + 1, ID: id, Err: err})
+	}
+
+	// Validate records and decide which input IDs can be kept
+	var plans []importPlan
+	seen := make(map[int]bool)
+	kept := make(map[int]bool)
+	for i, task := range records {
+		task = task.clone()
+		if task.Title == "" {
+			reject(i, task, ErrEmptyTitle)
+			continue
+		}
+		if err := task.validate(); err != nil {
+			reject(i, task, err)
+			continue
+		}
+		if task.ID != 0 {
+			if seen[task.ID] {
+				reject(i, task, ErrDuplicateID)
+				continue
+			}
+			seen[task.ID] = true
+		}
+		if task.ID >= tm.nextID {
+			kept[task.ID] = true
+		}
+		plans = append(plans, importPlan{record: i, task: task})
+	}
+
+	// Assign new IDs to the rest, skipping IDs kept from the input
+	next := tm.nextID
+	newIDs := make(map[int]int, len(plans))
+	for i := range plans {
+		task := &plans[i].task
+		source := task.ID
+		if !kept[source] {
+			for kept[next] {
+				next++
+			}
+			task.ID = next
+			next++
+			if source > 0 {
+				report.Remapped[source] = task.ID
+			}
+		}
+		if source != 0 {
+			newIDs[source] = task.ID
+		}
+		if existing, ok := tm.tasks[source]; ok && source > 0 {
+			report.Conflicts = append(report.Conflicts, ImportConflict{
+				ID: source, NewID: task.ID, Existing: existing.clone(), Incoming: records[plans[i].record].clone(),
+			})
+		}
+	}
+
+	// Accept tasks once everything they link to is accepted, so parents and
+	// blockers are always added first and links never form a cycle
+	accepted := make(map[int]bool)
+	open := make(map[int]bool) // accepted tasks that are not done
+	rejected := make(map[int]bool)
+	var order []Task
+	pending := plans
+	for progress := true; progress && len(pending) > 0; {
+		progress = false
+		var rest []importPlan
+		for _, plan := range pending {
+			links, ok := resolveLinks(plan.task, newIDs, rejected)
+			if !ok {
+				reject(plan.record, records[plan.record], fmt.Errorf("linked task not imported: %w", ErrTaskNotFound))
+				rejected[plan.task.ID] = true
+				progress = true
+				continue
+			}
+			if !allAccepted(links, accepted) {
+				rest = append(rest, plan)
+				continue
+			}
+			task := plan.task
+			task.ParentID, task.BlockedBy = links.parent, links.blockedBy
+			if task.Done && slices.ContainsFunc(task.BlockedBy, func(id int) bool { return open[id] }) {
+				reject(plan.record, records[plan.record], ErrBlocked)
+				rejected[task.ID] = true
+				progress = true
+				continue
+			}
+			accepted[task.ID] = true
+			open[task.ID] = !task.Done
+			order = append(order, task)
+			progress = true
+		}
+		pending = rest
+	}
+	for _, plan := range pending {
+		reject(plan.record, records[plan.record], ErrDependencyCycle)
+	}
+	slices.SortFunc(report.Errors, func(a, b ImportError) int { return a.Record - b.Record })
+
+	now := tm.now()
+	for _, task := range order {
+		if task.CreatedAt.IsZero() {
+			task.CreatedAt = now
+		}
+		if !opts.DryRun {
+			if err := tm.putLocked(task); err != nil {
+				return report, err
+			}
+		}
+		report.Imported = append(report.Imported, task.clone())
+	}
+	return report, nil
+}
+
+// importLinks holds the parent and blocker IDs of an input task after remapping
+type importLinks struct {
+	parent    int
+	blockedBy []int
+}
+
+// resolveLinks maps input IDs to assigned IDs, false if a linked task is
+// missing from the input or was rejected
+func resolveLinks(task Task, newIDs map[int]int, rejected map[int]bool) (importLinks, bool) {
+	var links importLinks
+	if task.ParentID != 0 {
+		id, ok := newIDs[task.ParentID]
+		if !ok || rejected[id] {
+			return importLinks{}, false
+		}
+		links.parent = id
+	}
+	for _, blocker := range task.BlockedBy {
+		id, ok := newIDs[blocker]
+		if !ok || rejected[id] {
+			return importLinks{}, false
+		}
+		links.blockedBy = append(links.blockedBy, id)
+	}
+	links.blockedBy = normalizeIDs(links.blockedBy)
+	return links, true
+}
+
+func allAccepted(links importLinks, accepted map[int]bool) bool {
+	if links.parent != 0 && !accepted[links.parent] {
+		return false
+	}
+	for _, id := range links.blockedBy {
+		if !accepted[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// csvHeader lists the CSV columns written by Export, Import matches columns by name
+var csvHeader = []string{
+	"id", "title", "description", "done", "created_at", "due_date", "priority", "tags",
+	"completed_at", "recurrence", "reminders", "parent_id", "blocked_by",
+}
+
+// csvListSeparator separates the items of list columns such as tags
+const csvListSeparator = ";"
+
+func writeCSV(w io.Writer, tasks []Task) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		recurrence := ""
+		if task.Recurrence != nil {
+			recurrence = task.Recurrence.String()
+		}
+		reminders := make([]string, len(task.Reminders))
+		for i, before := range task.Reminders {
+			reminders[i] = before.String()
+		}
+		blockedBy := make([]string, len(task.BlockedBy))
+		for i, id := range task.BlockedBy {
+			blockedBy[i] = strconv.Itoa(id)
+		}
+		parent := ""
+		if task.ParentID != 0 {
+			parent = strconv.Itoa(task.ParentID)
+		}
+
+		err := cw.Write([]string{
+			strconv.Itoa(task.ID),
+			task.Title,
+			task.Description,
+			strconv.FormatBool(task.Done),
+			task.CreatedAt.Format(time.RFC3339Nano),
+			formatOptionalTime(task.DueDate),
+			task.Priority.String(),
+			strings.Join(task.Tags, csvListSeparator),
+			formatOptionalTime(task.CompletedAt),
+			recurrence,
+			strings.Join(reminders, csvListSeparator),
+			parent,
+			strings.Join(blockedBy, csvListSeparator),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) ([]Task, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv: missing title column")
+	}
+
+	var tasks []Task
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return tasks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		task, err := parseCSVRow(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		tasks = append(tasks, task)
+	}
+}
+
+func parseCSVRow(row []string, columns map[string]int) (Task, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var task Task
+	var err error
+	task.Title = field("title")
+	task.Description = field("description")
+	if v := field("id"); v != "" {
+		if task.ID, err = strconv.Atoi(v); err != nil {
+			return Task{}, fmt.Errorf("id: %w", err)
+		}
+	}
+	if v := field("done"); v != "" {
+		if task.Done, err = strconv.ParseBool(v); err != nil {
+			return Task{}, fmt.Errorf("done: %w", err)
+		}
+	}
+	if v := field("created_at"); v != "" {
+		if task.CreatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return Task{}, fmt.Errorf("created_at: %w", err)
+		}
+	}
+	if task.DueDate, err = parseOptionalTime(field("due_date")); err != nil {
+		return Task{}, fmt.Errorf("due_date: %w", err)
+	}
+	if task.CompletedAt, err = parseOptionalTime(field("completed_at")); err != nil {
+		return Task{}, fmt.Errorf("completed_at: %w", err)
+	}
+	if v := field("priority"); v != "" {
+		if task.Priority, err = ParsePriority(v); err != nil {
+			return Task{}, err
+		}
+	}
+	if v := field("tags"); v != "" {
+		task.Tags = normalizeTags(strings.Split(v, csvListSeparator))
+	}
+	if v := field("recurrence"); v != "" {
+		rule, err := ParseRRule(v)
+		if err != nil {
+			return Task{}, err
+		}
+		task.Recurrence = &rule
+	}
+	for _, v := range splitList(field("reminders")) {
+		before, err := time.ParseDuration(v)
+		if err != nil {
+			return Task{}, fmt.Errorf("reminders: %w", err)
+		}
+		task.Reminders = append(task.Reminders, before)
+	}
+	if v := field("parent_id"); v != "" {
+		if task.ParentID, err = strconv.Atoi(v); err != nil {
+			return Task{}, fmt.Errorf("parent_id: %w", err)
+		}
+	}
+	for _, v := range splitList(field("blocked_by")) {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return Task{}, fmt.Errorf("blocked_by: %w", err)
+		}
+		task.BlockedBy = append(task.BlockedBy, id)
+	}
+	return task, nil
+}
+
+// ParsePriority accepts a priority name such as "high" or its number
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for p := PriorityNone; p <= PriorityHigh; p++ {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || !Priority(n).Valid() {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPriority, s)
+	}
+	return Priority(n), nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(s, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseOptionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
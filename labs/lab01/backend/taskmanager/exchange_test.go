@@ -0,0 +1,283 @@
+package taskmanager
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExchangeTestManager(t *testing.T) *TaskManager {
+	t.Helper()
+	tm := NewTaskManager()
+	due := time.Date(2025, 7, 1, 9, 30, 0, 0, time.UTC)
+	parent, _ := tm.AddTask("Release, v1", "Line one\nline two; with separators",
+		WithDueDate(due), WithPriority(PriorityHigh), WithTags("work", "release"),
+		WithRecurrence(Recurrence{Freq: Weekly, Weekdays: []time.Weekday{time.Tuesday}}),
+		WithReminders(time.Hour, 24*time.Hour+30*time.Minute))
+	blocker, _ := tm.AddTask("Write changelog with a rather long title that needs folding in iCalendar output", "")
+	tm.AddTask("Tag release", "", WithParent(parent.ID), WithBlockedBy(blocker.ID))
+	if err := tm.UpdateTask(blocker.ID, blocker.Title, "", true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	return tm
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatCSV, FormatICal} {
+		t.Run(format.String(), func(t *testing.T) {
+			src := newExchangeTestManager(t)
+			var buf bytes.Buffer
+			if err := src.Export(&buf, format); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+
+			dst := NewTaskManager()
+			report, err := dst.Import(&buf, format, ImportOptions{})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if err := report.Err(); err != nil {
+				t.Fatalf("unexpected record errors: %v", err)
+			}
+
+			want := src.ListTasks(nil)
+			got := dst.ListTasks(nil)
+			if len(got) != len(want) {
+				t.Fatalf("imported %d tasks, want %d", len(got), len(want))
+			}
+			for i := range want {
+				w, g := want[i], got[i]
+				if g.ID != w.ID || g.Title != w.Title || g.Description != w.Description || g.Done != w.Done ||
+					g.Priority != w.Priority || g.ParentID != w.ParentID ||
+					strings.Join(g.Tags, ",") != strings.Join(w.Tags, ",") ||
+					len(g.BlockedBy) != len(w.BlockedBy) || len(g.Reminders) != len(w.Reminders) {
+					t.Errorf("task %d differs:\n got %+v\nwant %+v", w.ID, g, w)
+				}
+				if (w.DueDate == nil) != (g.DueDate == nil) || (w.DueDate != nil && !g.DueDate.Equal(*w.DueDate)) {
+					t.Errorf("task %d due date %v, want %v", w.ID, g.DueDate, w.DueDate)
+				}
+				if (w.Recurrence == nil) != (g.Recurrence == nil) || (w.Recurrence != nil && g.Recurrence.String() != w.Recurrence.String()) {
+					t.Errorf("task %d recurrence %v, want %v", w.ID, g.Recurrence, w.Recurrence)
+				}
+				for j := range w.Reminders {
+					if g.Reminders[j] != w.Reminders[j] {
+						t.Errorf("task %d reminders %v, want %v", w.ID, g.Reminders, w.Reminders)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestImportRemapsCollidingIDs(t *testing.T) {
+	src := newExchangeTestManager(t)
+	var buf bytes.Buffer
+	src.Export(&buf, FormatJSON)
+
+	dst := NewTaskManager()
+	existing, _ := dst.AddTask("Existing", "")
+	report, err := dst.Import(&buf, FormatJSON, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// ID 1 is taken, so every input task below nextID is moved after it
+	if len(report.Conflicts) != 1 || report.Conflicts[0].ID != existing.ID || report.Conflicts[0].Existing.Title != "Existing" {
+		t.Errorf("unexpected conflicts: %+v", report.Conflicts)
+	}
+	if report.Remapped[1] == 1 || report.Remapped[1] == 0 {
+		t.Errorf("ID 1 should have been remapped, got %v", report.Remapped)
+	}
+	if _, ok := report.Remapped[2]; ok {
+		t.Errorf("free ID 2 should have been kept, got %v", report.Remapped)
+	}
+
+	child, err := dst.GetTask(3)
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if child.ParentID != report.Remapped[1] || len(child.BlockedBy) != 1 || child.BlockedBy[0] != 2 {
+		t.Errorf("links were not rewritten: %+v", child)
+	}
+	if got, _ := dst.GetTask(existing.ID); got.Title != "Existing" {
+		t.Error("existing task was overwritten")
+	}
+
+	next, _ := dst.AddTask("After import", "")
+	if next.ID != report.Remapped[1]+1 {
+		t.Errorf("nextID not moved past imported tasks, got %d", next.ID)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Existing", "")
+	input := `[{"id": 1, "title": "Clash"}, {"id": 5, "title": "Free"}, {"id": 6, "title": ""}]`
+
+	report, err := tm.Import(strings.NewReader(input), FormatJSON, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !report.DryRun || len(report.Imported) != 2 || len(report.Conflicts) != 1 || len(report.Errors) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if !errors.Is(report.Err(), ErrEmptyTitle) {
+		t.Errorf("expected ErrEmptyTitle in report, got %v", report.Err())
+	}
+	if report.Errors[0].Record != 3 || report.Errors[0].ID != 6 {
+		t.Errorf("unexpected error location: %+v", report.Errors[0])
+	}
+	if got := len(tm.ListTasks(nil)); got != 1 {
+		t.Errorf("dry run changed the manager, it has %d tasks", got)
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	tm := NewTaskManager()
+	input := "id,title,parent_id,blocked_by,priority\n" +
+		"1,Parent,,,high\n" +
+		"2,,,,\n" +
+		"3,Child of rejected,2,,\n" +
+		"4,Blocked by missing,,99,\n" +
+		"5,Cycle A,,6,\n" +
+		"6,Cycle B,,5,\n" +
+		"1,Duplicate,,,\n" +
+		"7,Bad priority,,,urgent\n"
+
+	_, err := tm.Import(strings.NewReader(input), FormatCSV, ImportOptions{})
+	if !errors.Is(err, ErrInvalidPriority) {
+		t.Fatalf("expected parse error for bad priority, got %v", err)
+	}
+
+	input = strings.TrimSuffix(input, "7,Bad priority,,,urgent\n")
+	report, err := tm.Import(strings.NewReader(input), FormatCSV, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	want := map[int]error{
+		2: ErrEmptyTitle,
+		3: ErrTaskNotFound,
+		4: ErrTaskNotFound,
+		5: ErrDependencyCycle,
+		6: ErrDependencyCycle,
+		7: ErrDuplicateID,
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("got errors %v", report.Errors)
+	}
+	for _, e := range report.Errors {
+		if !errors.Is(e, want[e.Record]) {
+			t.Errorf("record %d: got %v, want %v", e.Record, e.Err, want[e.Record])
+		}
+	}
+	if len(report.Imported) != 1 || report.Imported[0].Title != "Parent" || report.Imported[0].Priority != PriorityHigh {
+		t.Errorf("unexpected imported tasks: %+v", report.Imported)
+	}
+}
+
+func TestImportRejectsDoneBlockedTask(t *testing.T) {
+	tm := NewTaskManager()
+	input := "id,title,done,blocked_by\n" +
+		"1,Open blocker,false,\n" +
+		"2,Done while blocked,true,1\n" +
+		"3,Done blocker,true,\n" +
+		"4,Done after blocker,true,3\n" +
+		"5,Blocked by rejected,false,2\n"
+
+	report, err := tm.Import(strings.NewReader(input), FormatCSV, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	want := map[int]error{
+		2: ErrBlocked,
+		5: ErrTaskNotFound,
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("got errors %v", report.Errors)
+	}
+	for _, e := range report.Errors {
+		if !errors.Is(e, want[e.Record]) {
+			t.Errorf("record %d: got %v, want %v", e.Record, e.Err, want[e.Record])
+		}
+	}
+	if got := titles(report.Imported); len(got) != 3 {
+		t.Errorf("unexpected imported tasks: %v", got)
+	}
+}
+
+func TestImportForeignICal(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Other//App//EN\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:abc-123@example.com\r\n" +
+		"SUMMARY:Buy milk\\, eggs\r\n" +
+		"DUE;VALUE=DATE:20250701\r\n" +
+		"PRIORITY:2\r\n" +
+		"CATEGORIES:Home,Errands\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"TRIGGER:-PT30M\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:def-456@example.com\r\n" +
+		"SUMMARY:Cook din\r\n" +
+		" ner\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"RELATED-TO:abc-123@example.com\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	tm := NewTaskManager()
+	report, err := tm.Import(strings.NewReader(input), FormatICal, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if err := report.Err(); err != nil {
+		t.Fatalf("unexpected record errors: %v", err)
+	}
+
+	tasks := tm.ListTasks(nil)
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+	milk, dinner := tasks[0], tasks[1]
+	if milk.Title != "Buy milk, eggs" || milk.Priority != PriorityHigh || !milk.HasTag("errands") ||
+		milk.DueDate == nil || len(milk.Reminders) != 1 || milk.Reminders[0] != 30*time.Minute {
+		t.Errorf("unexpected first task: %+v", milk)
+	}
+	if dinner.Title != "Cook dinner" || !dinner.Done || dinner.ParentID != milk.ID {
+		t.Errorf("unexpected second task: %+v", dinner)
+	}
+}
+
+func TestICalDuration(t *testing.T) {
+	tests := []struct {
+		text string
+		d    time.Duration
+	}{
+		{"PT0S", 0},
+		{"-PT15M", -15 * time.Minute},
+		{"-P1DT2H30M", -(26*time.Hour + 30*time.Minute)},
+		{"P2D", 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := formatICalDuration(tt.d); got != tt.text {
+			t.Errorf("formatICalDuration(%v) = %q, want %q", tt.d, got, tt.text)
+		}
+		if got, err := parseICalDuration(tt.text); err != nil || got != tt.d {
+			t.Errorf("parseICalDuration(%q) = %v, %v", tt.text, got, err)
+		}
+	}
+	if got, err := parseICalDuration("-P1W"); err != nil || got != -7*24*time.Hour {
+		t.Errorf("parseICalDuration(-P1W) = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "P", "PT", "1H", "PT1X", "PTH"} {
+		if _, err := parseICalDuration(bad); err == nil {
+			t.Errorf("parseICalDuration(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package taskmanager
+
+import (
+	"cmp"
+	"sort"
+	"time"
+)
+
+// SortField selects the field FilterTasks orders results by
+type SortField int
+
+// Supported sort fields, SortByID is the default
+const (
+	SortByID SortField = iota
+	SortByCreatedAt
+	SortByDueDate
+	SortByPriority
+	SortByTitle
+)
+
+// TaskFilter narrows and orders the result of FilterTasks, zero-valued fields
+// do not filter anything
+type TaskFilter struct {
+	// Done keeps only tasks with the given done status
+	Done *bool
+	// Tag keeps only tasks carrying the tag, ignoring case
+	Tag string
+	// Priority keeps only tasks with exactly this priority
+	Priority *Priority
+	// Overdue keeps only open tasks whose due date has passed
+	Overdue bool
+	// DueBefore keeps only tasks due strictly before this time
+	DueBefore time.Time
+
+	// SortBy selects the ordering, tasks without a due date sort last for SortByDueDate
+	SortBy SortField
+	// Descending reverses the ordering
+	Descending bool
+}
+
+// ListOption narrows or orders the result of ListTasks like the matching TaskFilter field
+type ListOption func(*TaskFilter)
+
+// Tagged keeps only tasks carrying tag, ignoring case
+func Tagged(tag string) ListOption {
+	return func(f *TaskFilter) {
+		f.Tag = tag
+	}
+}
+
+// OfPriority keeps only tasks with exactly priority p
+func OfPriority(p Priority) ListOption {
+	return func(f *TaskFilter) {
+		f.Priority = &p
+	}
+}
+
+// OnlyOverdue keeps only open tasks whose due date has passed
+func OnlyOverdue() ListOption {
+	return func(f *TaskFilter) {
+		f.Overdue = true
+	}
+}
+
+// DueBefore keeps only tasks due strictly before t
+func DueBefore(t time.Time) ListOption {
+	return func(f *TaskFilter) {
+		f.DueBefore = t
+	}
+}
+
+// SortedBy orders the tasks by field, in reverse if descending
+func SortedBy(field SortField, descending bool) ListOption {
+	return func(f *TaskFilter) {
+		f.SortBy = field
+		f.Descending = descending
+	}
+}
+
+// FilterTasks returns the tasks matching filter in the requested order, returns
+// an empty slice if no tasks match. It is ListTasks taking the whole filter at once.
+func (tm *TaskManager) FilterTasks(filter TaskFilter) []Task {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	now := tm.now()
+	result := make([]Task, 0, len(tm.tasks))
+	for _, task := range tm.tasks {
+		if filter.matches(task, now) {
+			result = append(result, task.clone())
+		}
+	}
+	sortTasks(result, filter.SortBy, filter.Descending)
+	return result
+}
+
+// matches reports whether task passes every set criterion of the filter
+func (f TaskFilter) matches(task Task, now time.Time) bool {
+	if f.Done != nil && task.Done != *f.Done {
+		return false
+	}
+	if f.Tag != "" && !task.HasTag(f.Tag) {
+		return false
+	}
+	if f.Priority != nil && task.Priority != *f.Priority {
+		return false
+	}
+	if f.Overdue && !task.IsOverdue(now) {
+		return false
+	}
+	if !f.DueBefore.IsZero() && (task.DueDate == nil || !task.DueDate.Before(f.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// sortTasks orders tasks by field, ties are broken by ID so the order is stable
+func sortTasks(tasks []Task, field SortField, descending bool) {
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if field == SortByDueDate && (a.DueDate == nil) != (b.DueDate == nil) {
+			// Tasks without a due date go last regardless of direction
+			return a.DueDate != nil
+		}
+		c := compareTasks(a, b, field)
+		if c == 0 {
+			return a.ID < b.ID
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareTasks returns -1, 0 or 1 comparing a and b on field
+func compareTasks(a, b Task, field SortField) int {
+	switch field {
+	case SortByCreatedAt:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case SortByDueDate:
+		if a.DueDate == nil || b.DueDate == nil {
+			return 0
+		}
+		return a.DueDate.Compare(*b.DueDate)
+	case SortByPriority:
+		return cmp.Compare(a.Priority, b.Priority)
+	case SortByTitle:
+		return cmp.Compare(a.Title, b.Title)
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
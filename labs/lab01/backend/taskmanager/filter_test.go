@@ -0,0 +1,164 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+)
+
+func newFilterTestManager(t *testing.T, now time.Time) *TaskManager {
+	t.Helper()
+	tm := NewTaskManager()
+	tm.SetClock(newFakeClock(now))
+
+	add := func(title string, opts ...TaskOption) Task {
+		task, err := tm.AddTask(title, "", opts...)
+		if err != nil {
+			t.Fatalf("AddTask(%q) failed: %v", title, err)
+		}
+		return task
+	}
+	add("overdue", WithDueDate(now.Add(-time.Hour)), WithPriority(PriorityHigh), WithTags("Work"))
+	add("tomorrow", WithDueDate(now.Add(24*time.Hour)), WithPriority(PriorityLow), WithTags("home", "work"))
+	add("no due date", WithPriority(PriorityMedium))
+	done := add("done overdue", WithDueDate(now.Add(-2*time.Hour)), WithTags("home"))
+	if err := tm.UpdateTask(done.ID, done.Title, done.Description, true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	return tm
+}
+
+func titles(tasks []Task) []string {
+	result := make([]string, len(tasks))
+	for i, task := range tasks {
+		result[i] = task.Title
+	}
+	return result
+}
+
+func TestFilterTasks(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tm := newFilterTestManager(t, now)
+	high := PriorityHigh
+	open := false
+
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		want   []string
+	}{
+		{"no filter", TaskFilter{}, []string{"overdue", "tomorrow", "no due date", "done overdue"}},
+		{"open", TaskFilter{Done: &open}, []string{"overdue", "tomorrow", "no due date"}},
+		{"tag ignores case", TaskFilter{Tag: "WORK"}, []string{"overdue", "tomorrow"}},
+		{"priority", TaskFilter{Priority: &high}, []string{"overdue"}},
+		{"overdue skips done", TaskFilter{Overdue: true}, []string{"overdue"}},
+		{"due before", TaskFilter{DueBefore: now}, []string{"overdue", "done overdue"}},
+		{"sort by due date", TaskFilter{SortBy: SortByDueDate}, []string{"done overdue", "overdue", "tomorrow", "no due date"}},
+		{"sort by due date descending", TaskFilter{SortBy: SortByDueDate, Descending: true}, []string{"tomorrow", "overdue", "done overdue", "no due date"}},
+		{"sort by priority descending", TaskFilter{SortBy: SortByPriority, Descending: true}, []string{"overdue", "no due date", "tomorrow", "done overdue"}},
+		{"combined", TaskFilter{Tag: "home", Done: &open}, []string{"tomorrow"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := titles(tm.FilterTasks(tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletedAt(t *testing.T) {
+	tm := NewTaskManager()
+	task, _ := tm.AddTask("Task", "")
+
+	if err := tm.UpdateTask(task.ID, task.Title, "", true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	done, _ := tm.GetTask(task.ID)
+	if done.CompletedAt == nil {
+		t.Fatal("CompletedAt should be set when task is marked done")
+	}
+
+	// Updating an already done task keeps the original completion time
+	completed := *done.CompletedAt
+	tm.UpdateTask(task.ID, "Renamed", "", true)
+	done, _ = tm.GetTask(task.ID)
+	if done.CompletedAt == nil || !done.CompletedAt.Equal(completed) {
+		t.Errorf("CompletedAt changed on unrelated update: %v", done.CompletedAt)
+	}
+
+	tm.UpdateTask(task.ID, "Renamed", "", false)
+	reopened, _ := tm.GetTask(task.ID)
+	if reopened.CompletedAt != nil {
+		t.Error("CompletedAt should be cleared when task is reopened")
+	}
+}
+
+func TestTaskOptions(t *testing.T) {
+	tm := NewTaskManager()
+
+	if _, err := tm.AddTask("Task", "", WithPriority(Priority(42))); err != ErrInvalidPriority {
+		t.Errorf("expected ErrInvalidPriority, got %v", err)
+	}
+
+	task, err := tm.AddTask("Task", "", WithTags(" Go ", "go", "", "Lab"))
+	if err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	if len(task.Tags) != 2 || task.Tags[0] != "go" || task.Tags[1] != "lab" {
+		t.Errorf("unexpected tags: %v", task.Tags)
+	}
+
+	// Returned tasks must not alias the manager's copy
+	task.Tags[0] = "changed"
+	stored, _ := tm.GetTask(task.ID)
+	if stored.Tags[0] != "go" {
+		t.Error("modifying a returned task changed the stored task")
+	}
+
+	due := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm.UpdateTask(task.ID, task.Title, "", false, WithDueDate(due))
+	tm.UpdateTask(task.ID, task.Title, "", false, WithoutDueDate())
+	stored, _ = tm.GetTask(task.ID)
+	if stored.DueDate != nil {
+		t.Errorf("expected due date to be cleared, got %v", stored.DueDate)
+	}
+}
+
+func TestListTasksOptions(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tm := newFilterTestManager(t, now)
+	open := false
+
+	tests := []struct {
+		name string
+		done *bool
+		opts []ListOption
+		want []string
+	}{
+		{"no options", nil, nil, []string{"overdue", "tomorrow", "no due date", "done overdue"}},
+		{"open tagged", &open, []ListOption{Tagged("home")}, []string{"tomorrow"}},
+		{"priority", nil, []ListOption{OfPriority(PriorityLow)}, []string{"tomorrow"}},
+		{"overdue", nil, []ListOption{OnlyOverdue()}, []string{"overdue"}},
+		{"due before, sorted", nil, []ListOption{DueBefore(now), SortedBy(SortByDueDate, false)}, []string{"done overdue", "overdue"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := titles(tm.ListTasks(tt.done, tt.opts...))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
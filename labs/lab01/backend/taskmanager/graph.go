@@ -0,0 +1,175 @@
+package taskmanager
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Subtasks returns the direct subtasks of the task ordered by ID, returns an error if the task is not found
+func (tm *TaskManager) Subtasks(id int) ([]Task, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	if _, ok := tm.tasks[id]; !ok {
+		return nil, ErrTaskNotFound
+	}
+	children := tm.childrenLocked(id)
+	result := make([]Task, len(children))
+	for i, childID := range children {
+		result[i] = tm.tasks[childID].clone()
+	}
+	return result, nil
+}
+
+// TopologicalOrder returns all tasks ordered so that every task comes after
+// the tasks it is blocked by, ties are broken by ID
+func (tm *TaskManager) TopologicalOrder() ([]Task, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	// Kahn's algorithm, always picking the smallest ready ID
+	pending := make(map[int]int, len(tm.tasks))
+	dependents := make(map[int][]int)
+	for id, task := range tm.tasks {
+		for _, blocker := range task.BlockedBy {
+			if _, ok := tm.tasks[blocker]; !ok {
+				continue
+			}
+			pending[id]++
+			dependents[blocker] = append(dependents[blocker], id)
+		}
+	}
+
+	var ready []int
+	for id := range tm.tasks {
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	result := make([]Task, 0, len(tm.tasks))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		id := ready[0]
+		ready = ready[1:]
+		result = append(result, tm.tasks[id].clone())
+		for _, dependent := range dependents[id] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(result) != len(tm.tasks) {
+		return nil, ErrDependencyCycle
+	}
+	return result, nil
+}
+
+// checkLinksLocked verifies that the parent and blockers of task exist and
+// that neither link closes a cycle, a missing one is an ErrInvalidReference
+// that also matches ErrTaskNotFound
+func (tm *TaskManager) checkLinksLocked(task Task) error {
+	if task.ParentID != 0 {
+		if _, ok := tm.tasks[task.ParentID]; !ok {
+			return fmt.Errorf("%w: parent %d: %w", ErrInvalidReference, task.ParentID, ErrTaskNotFound)
+		}
+		// Walk up from the new parent, reaching the task itself means a cycle
+		id := task.ParentID
+		for steps := 0; id != 0 && steps <= len(tm.tasks); steps++ {
+			if id == task.ID {
+				return fmt.Errorf("parent %d: %w", task.ParentID, ErrDependencyCycle)
+			}
+			id = tm.tasks[id].ParentID
+		}
+	}
+
+	for _, blocker := range task.BlockedBy {
+		if _, ok := tm.tasks[blocker]; !ok {
+			return fmt.Errorf("%w: blocker %d: %w", ErrInvalidReference, blocker, ErrTaskNotFound)
+		}
+		if task.ID != 0 && tm.reachesLocked(blocker, task.ID) {
+			return fmt.Errorf("blocker %d: %w", blocker, ErrDependencyCycle)
+		}
+	}
+	return nil
+}
+
+// reachesLocked reports whether target can be reached from start by following blocked-by links
+func (tm *TaskManager) reachesLocked(start, target int) bool {
+	visited := make(map[int]bool)
+	stack := []int{start}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == target {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		stack = append(stack, tm.tasks[id].BlockedBy...)
+	}
+	return false
+}
+
+// isBlockedLocked reports whether any task blocking task is still open
+func (tm *TaskManager) isBlockedLocked(task Task) bool {
+	for _, blocker := range task.BlockedBy {
+		if b, ok := tm.tasks[blocker]; ok && !b.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// childrenLocked returns the IDs of the direct subtasks of id in ascending order
+func (tm *TaskManager) childrenLocked(id int) []int {
+	var children []int
+	for childID, task := range tm.tasks {
+		if task.ParentID == id {
+			children = append(children, childID)
+		}
+	}
+	slices.Sort(children)
+	return children
+}
+
+// subtreeLocked returns id and all its descendants, every subtask before its parent
+func (tm *TaskManager) subtreeLocked(id int) []int {
+	var result []int
+	for _, child := range tm.childrenLocked(id) {
+		result = append(result, tm.subtreeLocked(child)...)
+	}
+	return append(result, id)
+}
+
+// unblockLocked removes the given IDs from the blocked-by lists of the remaining tasks
+func (tm *TaskManager) unblockLocked(removed []int) error {
+	ids := make([]int, 0, len(tm.tasks))
+	for id := range tm.tasks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		current := tm.tasks[id]
+		blockedBy := slices.DeleteFunc(slices.Clone(current.BlockedBy), func(blocker int) bool {
+			return slices.Contains(removed, blocker)
+		})
+		if len(blockedBy) == len(current.BlockedBy) {
+			continue
+		}
+		task := current.clone()
+		task.BlockedBy = blockedBy
+		if len(blockedBy) == 0 {
+			task.BlockedBy = nil
+		}
+		if err := tm.replaceLocked(current, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
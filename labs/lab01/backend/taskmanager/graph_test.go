@@ -0,0 +1,130 @@
+package taskmanager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubtasks(t *testing.T) {
+	tm := NewTaskManager()
+	parent, _ := tm.AddTask("Parent", "")
+	child1, _ := tm.AddTask("Child 1", "", WithParent(parent.ID))
+	child2, _ := tm.AddTask("Child 2", "", WithParent(parent.ID))
+	tm.AddTask("Grandchild", "", WithParent(child1.ID))
+
+	subtasks, err := tm.Subtasks(parent.ID)
+	if err != nil {
+		t.Fatalf("Subtasks failed: %v", err)
+	}
+	if len(subtasks) != 2 || subtasks[0].ID != child1.ID || subtasks[1].ID != child2.ID {
+		t.Errorf("unexpected subtasks: %+v", subtasks)
+	}
+	if _, err := tm.Subtasks(999); err != ErrTaskNotFound {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err := tm.AddTask("Orphan", "", WithParent(999)); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound for missing parent, got %v", err)
+	}
+}
+
+func TestParentCycle(t *testing.T) {
+	tm := NewTaskManager()
+	a, _ := tm.AddTask("A", "")
+	b, _ := tm.AddTask("B", "", WithParent(a.ID))
+	c, _ := tm.AddTask("C", "", WithParent(b.ID))
+
+	if err := tm.UpdateTask(a.ID, "A", "", false, WithParent(c.ID)); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle, got %v", err)
+	}
+	if err := tm.UpdateTask(a.ID, "A", "", false, WithParent(a.ID)); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle for self parent, got %v", err)
+	}
+	// Moving a subtree elsewhere is fine
+	if err := tm.UpdateTask(c.ID, "C", "", false, WithParent(a.ID)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBlockedBy(t *testing.T) {
+	tm := NewTaskManager()
+	design, _ := tm.AddTask("Design", "")
+	build, _ := tm.AddTask("Build", "", WithBlockedBy(design.ID))
+	ship, _ := tm.AddTask("Ship", "", WithBlockedBy(build.ID, design.ID, build.ID))
+
+	if len(ship.BlockedBy) != 2 {
+		t.Errorf("expected duplicate blockers to be dropped, got %v", ship.BlockedBy)
+	}
+	if err := tm.UpdateTask(design.ID, "Design", "", false, WithBlockedBy(ship.ID)); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle, got %v", err)
+	}
+	if err := tm.UpdateTask(design.ID, "Design", "", false, WithBlockedBy(design.ID)); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle for self dependency, got %v", err)
+	}
+	if _, err := tm.AddTask("Task", "", WithBlockedBy(999)); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound for missing blocker, got %v", err)
+	}
+
+	if err := tm.UpdateTask(build.ID, "Build", "", true); err != ErrBlocked {
+		t.Errorf("expected ErrBlocked, got %v", err)
+	}
+	if err := tm.UpdateTask(design.ID, "Design", "", true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if err := tm.UpdateTask(build.ID, "Build", "", true); err != nil {
+		t.Errorf("expected build to be unblocked, got %v", err)
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tm := NewTaskManager()
+	deploy, _ := tm.AddTask("Deploy", "")
+	test, _ := tm.AddTask("Test", "")
+	build, _ := tm.AddTask("Build", "")
+	tm.UpdateTask(deploy.ID, "Deploy", "", false, WithBlockedBy(test.ID, build.ID))
+	tm.UpdateTask(test.ID, "Test", "", false, WithBlockedBy(build.ID))
+	docs, _ := tm.AddTask("Docs", "")
+
+	order, err := tm.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder failed: %v", err)
+	}
+	want := []int{build.ID, test.ID, deploy.ID, docs.ID}
+	if len(order) != len(want) {
+		t.Fatalf("got %d tasks, want %d", len(order), len(want))
+	}
+	for i, id := range want {
+		if order[i].ID != id {
+			t.Errorf("position %d: got task %d (%s), want %d", i, order[i].ID, order[i].Title, id)
+		}
+	}
+}
+
+func TestDeleteTaskWithSubtasks(t *testing.T) {
+	tm := NewTaskManager()
+	parent, _ := tm.AddTask("Parent", "")
+	child, _ := tm.AddTask("Child", "", WithParent(parent.ID))
+	grandchild, _ := tm.AddTask("Grandchild", "", WithParent(child.ID))
+	other, _ := tm.AddTask("Other", "", WithBlockedBy(grandchild.ID, parent.ID))
+	keep, _ := tm.AddTask("Keep", "")
+	tm.UpdateTask(other.ID, "Other", "", false, WithBlockedBy(grandchild.ID, keep.ID))
+
+	if err := tm.DeleteTask(parent.ID); err != ErrHasSubtasks {
+		t.Fatalf("expected ErrHasSubtasks, got %v", err)
+	}
+	if _, err := tm.GetTask(child.ID); err != nil {
+		t.Fatal("rejected delete must not remove anything")
+	}
+
+	if err := tm.DeleteTask(parent.ID, WithCascade()); err != nil {
+		t.Fatalf("cascading DeleteTask failed: %v", err)
+	}
+	for _, id := range []int{parent.ID, child.ID, grandchild.ID} {
+		if _, err := tm.GetTask(id); err != ErrTaskNotFound {
+			t.Errorf("task %d should have been deleted", id)
+		}
+	}
+	updated, _ := tm.GetTask(other.ID)
+	if len(updated.BlockedBy) != 1 || updated.BlockedBy[0] != keep.ID {
+		t.Errorf("deleted blockers should be removed, got %v", updated.BlockedBy)
+	}
+}
@@ -0,0 +1,336 @@
+package taskmanager
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+// History errors
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Default bounds of the undo stack and the audit log, see SetHistoryLimits
+const (
+	DefaultUndoLimit  = 100
+	DefaultAuditLimit = 1000
+)
+
+// Op names the operation an audit entry records
+type Op string
+
+// Recorded operations
+const (
+	OpAdd    Op = "add"
+	OpUpdate Op = "update"
+	OpDelete Op = "delete"
+	OpImport Op = "import"
+	OpUndo   Op = "undo"
+	OpRedo   Op = "redo"
+)
+
+// Change is the state of one task before and after an operation, Before is
+// nil for created tasks and After is nil for deleted ones
+type Change struct {
+	Before *Task
+	After  *Task
+}
+
+// AuditEntry records one mutation of a TaskManager. A single operation can
+// change several tasks, e.g. a cascading delete or completing a recurring
+// task, all of them are listed in Changes.
+type AuditEntry struct {
+	// Seq increases by one for every entry
+	Seq    int
+	Time   time.Time
+	Actor  string
+	Op     Op
+	TaskID int
+	// Target is the Seq of the entry reverted or reapplied by an undo or redo
+	Target  int
+	Changes []Change
+}
+
+// clone returns a copy of the entry that shares no memory with the original
+func (e AuditEntry) clone() AuditEntry {
+	changes := make([]Change, len(e.Changes))
+	for i, c := range e.Changes {
+		changes[i] = Change{Before: cloneTaskPtr(c.Before), After: cloneTaskPtr(c.After)}
+	}
+	e.Changes = changes
+	return e
+}
+
+func cloneTaskPtr(t *Task) *Task {
+	if t == nil {
+		return nil
+	}
+	c := t.clone()
+	return &c
+}
+
+// history keeps the undo and redo stacks and the audit log, the zero value
+// uses the default limits and callers serialize access with the TaskManager lock
+type history struct {
+	pending    []Change
+	undo       []AuditEntry
+	redo       []AuditEntry
+	log        []AuditEntry
+	seq        int
+	undoLimit  int
+	auditLimit int
+}
+
+// record adds a change to the operation in progress
+func (h *history) record(before, after *Task) {
+	h.pending = append(h.pending, Change{Before: cloneTaskPtr(before), After: cloneTaskPtr(after)})
+}
+
+// commit turns the recorded changes into an audit entry, operations that
+// changed nothing leave no trace
+func (h *history) commit(op Op, actor string, taskID, target int, now time.Time) (AuditEntry, bool) {
+	if len(h.pending) == 0 {
+		return AuditEntry{}, false
+	}
+	h.seq++
+	entry := AuditEntry{Seq: h.seq, Time: now, Actor: actor, Op: op, TaskID: taskID, Target: target, Changes: h.pending}
+	h.pending = nil
+
+	h.log = appendBounded(h.log, entry, limitOr(h.auditLimit, DefaultAuditLimit))
+	if op != OpUndo && op != OpRedo {
+		h.undo = appendBounded(h.undo, entry, limitOr(h.undoLimit, DefaultUndoLimit))
+		h.redo = nil
+	}
+	return entry, true
+}
+
+func limitOr(limit, fallback int) int {
+	if limit == 0 {
+		return fallback
+	}
+	return limit
+}
+
+// appendBounded appends entry and drops the oldest entries beyond limit
+func appendBounded(entries []AuditEntry, entry AuditEntry, limit int) []AuditEntry {
+	entries = append(entries, entry)
+	if limit > 0 && len(entries) > limit {
+		entries = slices.Delete(entries, 0, len(entries)-limit)
+	}
+	return entries
+}
+
+// commitLocked ends the operation in progress, the caller must hold the write lock
+func (tm *TaskManager) commitLocked(op Op, actor string, taskID int) {
+	tm.history.commit(op, actor, taskID, 0, tm.now())
+}
+
+// SetHistoryLimits bounds how many operations can be undone and how many
+// audit entries are kept, the oldest are dropped first. Zero restores the
+// default and a negative limit removes the bound.
+func (tm *TaskManager) SetHistoryLimits(undo, audit int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.history.undoLimit = undo
+	tm.history.auditLimit = audit
+	if l := limitOr(undo, DefaultUndoLimit); l > 0 && len(tm.history.undo) > l {
+		tm.history.undo = slices.Delete(tm.history.undo, 0, len(tm.history.undo)-l)
+	}
+	if l := limitOr(audit, DefaultAuditLimit); l > 0 && len(tm.history.log) > l {
+		tm.history.log = slices.Delete(tm.history.log, 0, len(tm.history.log)-l)
+	}
+}
+
+// Undo reverts the most recent operation that has not been undone yet and
+// returns its audit entry, returns ErrNothingToUndo if there is none
+func (tm *TaskManager) Undo() (AuditEntry, error) {
+	return tm.undo("")
+}
+
+// Redo reapplies the most recently undone operation and returns its audit
+// entry, returns ErrNothingToRedo if there is none. Any new operation after
+// an undo clears the redo stack.
+func (tm *TaskManager) Redo() (AuditEntry, error) {
+	return tm.redo("")
+}
+
+func (tm *TaskManager) undo(actor string) (AuditEntry, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	h := &tm.history
+	if len(h.undo) == 0 {
+		return AuditEntry{}, ErrNothingToUndo
+	}
+	entry := h.undo[len(h.undo)-1]
+	if err := tm.applyLocked(entry, true); err != nil {
+		return AuditEntry{}, err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.commit(OpUndo, actor, entry.TaskID, entry.Seq, tm.now())
+	h.redo = append(h.redo, entry)
+	return entry.clone(), nil
+}
+
+func (tm *TaskManager) redo(actor string) (AuditEntry, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	h := &tm.history
+	if len(h.redo) == 0 {
+		return AuditEntry{}, ErrNothingToRedo
+	}
+	entry := h.redo[len(h.redo)-1]
+	if err := tm.applyLocked(entry, false); err != nil {
+		return AuditEntry{}, err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.commit(OpRedo, actor, entry.TaskID, entry.Seq, tm.now())
+	h.undo = append(h.undo, entry)
+	return entry.clone(), nil
+}
+
+// applyLocked reverts the changes of entry, or reapplies them. If a step fails
+// the steps already taken are reverted, leaving the tasks as they were, and
+// nothing is recorded.
+func (tm *TaskManager) applyLocked(entry AuditEntry, revert bool) error {
+	var err error
+	if revert {
+		for i := len(entry.Changes) - 1; i >= 0 && err == nil; i-- {
+			c := entry.Changes[i]
+			err = tm.restoreLocked(c.After, c.Before)
+		}
+	} else {
+		for i := 0; i < len(entry.Changes) && err == nil; i++ {
+			c := entry.Changes[i]
+			err = tm.restoreLocked(c.Before, c.After)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	applied := tm.history.pending
+	tm.history.pending = nil
+	for i := len(applied) - 1; i >= 0; i-- {
+		tm.restoreLocked(applied[i].After, applied[i].Before)
+	}
+	tm.history.pending = nil
+	return err
+}
+
+// restoreLocked moves one task from state from to state to, a nil state means
+// the task does not exist
+func (tm *TaskManager) restoreLocked(from, to *Task) error {
+	switch {
+	case to == nil:
+		if _, ok := tm.tasks[from.ID]; !ok {
+			return nil
+		}
+		return tm.removeLocked(from.ID)
+	case from == nil:
+		return tm.putLocked(to.clone())
+	}
+	current, ok := tm.tasks[to.ID]
+	if !ok {
+		return tm.putLocked(to.clone())
+	}
+	return tm.replaceLocked(current, to.clone())
+}
+
+// AuditFilter narrows the result of AuditLog, zero-valued fields do not filter anything
+type AuditFilter struct {
+	// TaskID keeps entries that changed the task
+	TaskID int
+	Actor  string
+	Op     Op
+	// Since and Until keep entries recorded in [Since, Until)
+	Since time.Time
+	Until time.Time
+	// Limit keeps only the most recent entries
+	Limit int
+}
+
+// AuditLog returns the recorded operations matching filter, oldest first
+func (tm *TaskManager) AuditLog(filter AuditFilter) []AuditEntry {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	var result []AuditEntry
+	for _, entry := range tm.history.log {
+		if filter.matches(entry) {
+			result = append(result, entry.clone())
+		}
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Op != "" && entry.Op != f.Op {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	if f.TaskID != 0 && entry.TaskID != f.TaskID {
+		return slices.ContainsFunc(entry.Changes, func(c Change) bool {
+			return (c.Before != nil && c.Before.ID == f.TaskID) || (c.After != nil && c.After.ID == f.TaskID)
+		})
+	}
+	return true
+}
+
+// Actor performs TaskManager operations on behalf of a named user so the
+// audit log records who made each change
+type Actor struct {
+	tm   *TaskManager
+	name string
+}
+
+// As returns an Actor that performs operations as name
+func (tm *TaskManager) As(name string) Actor {
+	return Actor{tm: tm, name: name}
+}
+
+// AddTask adds a task like TaskManager.AddTask
+func (a Actor) AddTask(title, description string, opts ...TaskOption) (Task, error) {
+	return a.tm.addTask(a.name, title, description, opts...)
+}
+
+// UpdateTask updates a task like TaskManager.UpdateTask
+func (a Actor) UpdateTask(id int, title, description string, done bool, opts ...TaskOption) error {
+	return a.tm.updateTask(a.name, id, title, description, done, opts...)
+}
+
+// DeleteTask deletes a task like TaskManager.DeleteTask
+func (a Actor) DeleteTask(id int, opts ...DeleteOption) error {
+	return a.tm.deleteTask(a.name, id, opts...)
+}
+
+// Import imports tasks like TaskManager.Import
+func (a Actor) Import(r io.Reader, format Format, opts ImportOptions) (ImportReport, error) {
+	return a.tm.importTasks(a.name, r, format, opts)
+}
+
+// Undo reverts the most recent operation like TaskManager.Undo
+func (a Actor) Undo() (AuditEntry, error) {
+	return a.tm.undo(a.name)
+}
+
+// Redo reapplies the most recently undone operation like TaskManager.Redo
+func (a Actor) Redo() (AuditEntry, error) {
+	return a.tm.redo(a.name)
+}
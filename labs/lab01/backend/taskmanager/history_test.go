@@ -0,0 +1,233 @@
+package taskmanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	tm := NewTaskManager()
+	task, _ := tm.AddTask("Task", "first")
+	if err := tm.UpdateTask(task.ID, "Task", "second", false); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if err := tm.DeleteTask(task.ID); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+
+	entry, err := tm.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if entry.Op != OpDelete || entry.TaskID != task.ID {
+		t.Errorf("undid unexpected entry: %+v", entry)
+	}
+	restored, err := tm.GetTask(task.ID)
+	if err != nil || restored.Description != "second" || !restored.CreatedAt.Equal(task.CreatedAt) {
+		t.Fatalf("deleted task not restored: %+v, %v", restored, err)
+	}
+
+	tm.Undo()
+	if got, _ := tm.GetTask(task.ID); got.Description != "first" {
+		t.Errorf("update not undone, description %q", got.Description)
+	}
+	tm.Undo()
+	if _, err := tm.GetTask(task.ID); err != ErrTaskNotFound {
+		t.Errorf("add not undone, got %v", err)
+	}
+	if _, err := tm.Undo(); err != ErrNothingToUndo {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+
+	for range 2 {
+		if _, err := tm.Redo(); err != nil {
+			t.Fatalf("Redo failed: %v", err)
+		}
+	}
+	if got, _ := tm.GetTask(task.ID); got.Description != "second" {
+		t.Errorf("redo did not reapply the update, description %q", got.Description)
+	}
+
+	// A new operation discards what is left to redo
+	tm.AddTask("Other", "")
+	if _, err := tm.Redo(); err != ErrNothingToRedo {
+		t.Errorf("expected ErrNothingToRedo, got %v", err)
+	}
+}
+
+func TestUndoCascadingDelete(t *testing.T) {
+	tm := NewTaskManager()
+	parent, _ := tm.AddTask("Parent", "")
+	child, _ := tm.AddTask("Child", "", WithParent(parent.ID))
+	other, _ := tm.AddTask("Other", "", WithBlockedBy(child.ID))
+
+	if err := tm.DeleteTask(parent.ID, WithCascade()); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+	if _, err := tm.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+
+	if got, err := tm.GetTask(child.ID); err != nil || got.ParentID != parent.ID {
+		t.Errorf("subtask not restored: %+v, %v", got, err)
+	}
+	if got, _ := tm.GetTask(other.ID); len(got.BlockedBy) != 1 || got.BlockedBy[0] != child.ID {
+		t.Errorf("dependency not restored: %v", got.BlockedBy)
+	}
+	if order, err := tm.TopologicalOrder(); err != nil || len(order) != 3 {
+		t.Errorf("inconsistent graph after undo: %v, %v", order, err)
+	}
+}
+
+func TestUndoRecurringCompletion(t *testing.T) {
+	tm := NewTaskManager()
+	due := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	task, _ := tm.AddTask("Standup", "", WithDueDate(due), WithRecurrence(Recurrence{Freq: Daily}))
+	if err := tm.UpdateTask(task.ID, "Standup", "", true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if got := len(tm.ListTasks(nil)); got != 2 {
+		t.Fatalf("expected the next occurrence to be created, got %d tasks", got)
+	}
+
+	if _, err := tm.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	tasks := tm.ListTasks(nil)
+	if len(tasks) != 1 || tasks[0].Done || tasks[0].CompletedAt != nil {
+		t.Errorf("completion not fully undone: %+v", tasks)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC))
+	tm := NewTaskManager()
+	tm.SetClock(clock)
+
+	alice, bob := tm.As("alice"), tm.As("bob")
+	a, _ := alice.AddTask("A", "")
+	clock.Advance(time.Hour)
+	b, _ := bob.AddTask("B", "")
+	bob.UpdateTask(a.ID, "A", "edited", false)
+	clock.Advance(time.Hour)
+	alice.Undo()
+	tm.DeleteTask(b.ID)
+	// Failed operations change nothing and are not logged
+	bob.DeleteTask(999)
+
+	log := tm.AuditLog(AuditFilter{})
+	if len(log) != 5 {
+		t.Fatalf("expected 5 entries, got %d: %+v", len(log), log)
+	}
+	for i, entry := range log {
+		if entry.Seq != i+1 {
+			t.Errorf("entry %d has Seq %d", i, entry.Seq)
+		}
+	}
+	if undo := log[3]; undo.Op != OpUndo || undo.Actor != "alice" || undo.Target != log[2].Seq {
+		t.Errorf("unexpected undo entry: %+v", undo)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int
+	}{
+		{"actor", AuditFilter{Actor: "bob"}, []int{2, 3}},
+		{"task", AuditFilter{TaskID: a.ID}, []int{1, 3, 4}},
+		{"op", AuditFilter{Op: OpAdd}, []int{1, 2}},
+		{"since", AuditFilter{Since: clock.Now()}, []int{4, 5}},
+		{"until", AuditFilter{Until: clock.Now().Add(-time.Hour)}, []int{1}},
+		{"limit", AuditFilter{Limit: 2}, []int{4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tm.AuditLog(tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %v", len(got), tt.want)
+			}
+			for i, seq := range tt.want {
+				if got[i].Seq != seq {
+					t.Errorf("entry %d: got Seq %d, want %d", i, got[i].Seq, seq)
+				}
+			}
+		})
+	}
+
+	log[0].Changes[0].After.Title = "mutated"
+	if got := tm.AuditLog(AuditFilter{})[0].Changes[0].After.Title; got != "A" {
+		t.Errorf("audit log shares memory with callers, title %q", got)
+	}
+}
+
+func TestHistoryLimits(t *testing.T) {
+	tm := NewTaskManager()
+	for range 5 {
+		tm.AddTask("Task", "")
+	}
+	tm.SetHistoryLimits(2, 3)
+
+	if got := len(tm.AuditLog(AuditFilter{})); got != 3 {
+		t.Errorf("expected audit log trimmed to 3, got %d", got)
+	}
+	undone := 0
+	for {
+		if _, err := tm.Undo(); err != nil {
+			break
+		}
+		undone++
+	}
+	if undone != 2 || len(tm.ListTasks(nil)) != 3 {
+		t.Errorf("undid %d operations, %d tasks left", undone, len(tm.ListTasks(nil)))
+	}
+	if log := tm.AuditLog(AuditFilter{}); len(log) != 3 || log[2].Op != OpUndo {
+		t.Errorf("unexpected audit log: %+v", log)
+	}
+}
+
+var errStoreDown = errors.New("store down")
+
+// failingStore is a MemoryStore that fails to save one task
+type failingStore struct {
+	*MemoryStore
+	failID int
+}
+
+func (s *failingStore) Save(task Task) error {
+	if task.ID == s.failID {
+		return errStoreDown
+	}
+	return s.MemoryStore.Save(task)
+}
+
+func TestUndoFailureKeepsEntry(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	tm, _ := NewTaskManagerWithStore(store)
+	parent, _ := tm.AddTask("Parent", "")
+	child, _ := tm.AddTask("Child", "", WithParent(parent.ID))
+	if err := tm.DeleteTask(parent.ID, WithCascade()); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+
+	// the child is restored first, then restoring the parent fails
+	store.failID = parent.ID
+	if _, err := tm.Undo(); err != errStoreDown {
+		t.Fatalf("expected the store error, got %v", err)
+	}
+	if _, err := tm.GetTask(child.ID); err != ErrTaskNotFound {
+		t.Errorf("expected the restored child to be rolled back, got %v", err)
+	}
+	if n := len(tm.AuditLog(AuditFilter{Op: OpUndo})); n != 0 {
+		t.Errorf("expected no audit entry for the failed undo, got %d", n)
+	}
+
+	store.failID = 0
+	entry, err := tm.Undo()
+	if err != nil || entry.Op != OpDelete {
+		t.Fatalf("expected the delete to be undone on retry, got %+v, %v", entry, err)
+	}
+	if _, err := tm.GetTask(parent.ID); err != nil {
+		t.Errorf("parent not restored: %v", err)
+	}
+}
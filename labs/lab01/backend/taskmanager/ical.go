@@ -0,0 +1,454 @@
+package taskmanager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalUIDSuffix is appended to task IDs to build VTODO UIDs, UIDs of this
+// form are mapped back to the task ID on import
+const icalUIDSuffix = "@taskmanager"
+
+// icalTimeLayout is the UTC date-time form of RFC 5545
+const icalTimeLayout = "20060102T150405Z"
+
+// icalLineLimit is the octet limit after which content lines are folded
+const icalLineLimit = 75
+
+// writeICal writes the tasks as VTODO components of a single VCALENDAR
+func writeICal(w io.Writer, tasks []Task, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeICalLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//lab01//taskmanager//EN")
+	for _, task := range tasks {
+		line("BEGIN", "VTODO")
+		line("UID", icalUID(task.ID))
+		line("DTSTAMP", formatICalTime(now))
+		line("CREATED", formatICalTime(task.CreatedAt))
+		line("SUMMARY", escapeICalText(task.Title))
+		if task.Description != "" {
+			line("DESCRIPTION", escapeICalText(task.Description))
+		}
+		if task.Done {
+			line("STATUS", "COMPLETED")
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		if task.CompletedAt != nil {
+			line("COMPLETED", formatICalTime(*task.CompletedAt))
+		}
+		if task.DueDate != nil {
+			line("DUE", formatICalTime(*task.DueDate))
+		}
+		if p := icalPriority(task.Priority); p != 0 {
+			line("PRIORITY", strconv.Itoa(p))
+		}
+		if len(task.Tags) > 0 {
+			tags := make([]string, len(task.Tags))
+			for i, tag := range task.Tags {
+				tags[i] = escapeICalText(tag)
+			}
+			line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if task.Recurrence != nil {
+			line("RRULE", task.Recurrence.String())
+		}
+		if task.ParentID != 0 {
+			line("RELATED-TO;RELTYPE=PARENT", icalUID(task.ParentID))
+		}
+		for _, blocker := range task.BlockedBy {
+			line("RELATED-TO;RELTYPE=DEPENDS-ON", icalUID(blocker))
+		}
+		for _, before := range task.Reminders {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", escapeICalText(task.Title))
+			line("TRIGGER", formatICalDuration(-before))
+			line("END", "VALARM")
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeICalLine writes a content line, folding it so no line exceeds the
+// octet limit without splitting UTF-8 sequences
+func writeICalLine(w *bufio.Writer, s string) {
+	limit := icalLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = icalLineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// icalProperty is one parsed content line
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// readICal reads every VTODO component, UIDs that were not written by
+// writeICal are given negative placeholder IDs so links between them still
+// resolve on import
+func readICal(r io.Reader) ([]Task, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	var current *Task
+	var links []icalLinks
+	inAlarm := false
+	ids := make(map[string]int)
+	idFor := func(uid string) int {
+		if id, ok := ids[uid]; ok {
+			return id
+		}
+		id, ok := parseICalUID(uid)
+		if !ok {
+			id = -(len(ids) + 1)
+		}
+		ids[uid] = id
+		return id
+	}
+
+	for n, raw := range lines {
+		prop, err := parseICalLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("ical line %d: %w", n+1, err)
+		}
+		switch {
+		case prop.name == "BEGIN" && prop.value == "VTODO":
+			tasks = append(tasks, Task{})
+			links = append(links, icalLinks{})
+			current = &tasks[len(tasks)-1]
+		case prop.name == "END" && prop.value == "VTODO":
+			current = nil
+		case current == nil:
+			// Properties outside a VTODO, such as VCALENDAR headers or other components
+		case prop.name == "BEGIN" && prop.value == "VALARM":
+			inAlarm = true
+		case prop.name == "END" && prop.value == "VALARM":
+			inAlarm = false
+		case inAlarm:
+			if prop.name == "TRIGGER" && prop.params["VALUE"] != "DATE-TIME" && prop.params["RELATED"] != "END" {
+				d, err := parseICalDuration(prop.value)
+				if err != nil {
+					return nil, fmt.Errorf("ical line %d: %w", n+1, err)
+				}
+				if d <= 0 {
+					current.Reminders = append(current.Reminders, -d)
+				}
+			}
+		default:
+			if err := applyICalProperty(current, &links[len(links)-1], prop, idFor); err != nil {
+				return nil, fmt.Errorf("ical line %d: %s: %w", n+1, prop.name, err)
+			}
+		}
+	}
+
+	for i := range tasks {
+		if links[i].parent != "" {
+			tasks[i].ParentID = idFor(links[i].parent)
+		}
+		for _, uid := range links[i].blockedBy {
+			tasks[i].BlockedBy = append(tasks[i].BlockedBy, idFor(uid))
+		}
+	}
+	return tasks, nil
+}
+
+// icalLinks keeps RELATED-TO UIDs until every UID in the file has an ID
+type icalLinks struct {
+	parent    string
+	blockedBy []string
+}
+
+func applyICalProperty(task *Task, links *icalLinks, prop icalProperty, idFor func(string) int) error {
+	var err error
+	switch prop.name {
+	case "UID":
+		task.ID = idFor(prop.value)
+	case "SUMMARY":
+		task.Title = unescapeICalText(prop.value)
+	case "DESCRIPTION":
+		task.Description = unescapeICalText(prop.value)
+	case "STATUS":
+		task.Done = prop.value == "COMPLETED"
+	case "CREATED":
+		task.CreatedAt, err = parseICalTime(prop.value, prop.params)
+	case "COMPLETED":
+		var t time.Time
+		t, err = parseICalTime(prop.value, prop.params)
+		task.CompletedAt = &t
+	case "DUE":
+		var t time.Time
+		t, err = parseICalTime(prop.value, prop.params)
+		task.DueDate = &t
+	case "PRIORITY":
+		var p int
+		p, err = strconv.Atoi(prop.value)
+		task.Priority = priorityFromICal(p)
+	case "CATEGORIES":
+		for _, tag := range splitICalList(prop.value) {
+			task.Tags = append(task.Tags, unescapeICalText(tag))
+		}
+		task.Tags = normalizeTags(task.Tags)
+	case "RRULE":
+		var rule Recurrence
+		rule, err = ParseRRule(prop.value)
+		task.Recurrence = &rule
+	case "RELATED-TO":
+		switch prop.params["RELTYPE"] {
+		case "", "PARENT":
+			links.parent = prop.value
+		case "DEPENDS-ON":
+			links.blockedBy = append(links.blockedBy, prop.value)
+		}
+	}
+	return err
+}
+
+// unfoldICalLines splits the input into content lines, joining folded lines
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalLine splits "NAME;PARAM=VALUE:value" into its parts
+func parseICalLine(line string) (icalProperty, error) {
+	// The value starts at the first colon that is not inside a quoted parameter
+	inQuotes := false
+	sep := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		}
+		if c == ':' && !inQuotes {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 {
+		return icalProperty{}, fmt.Errorf("malformed content line %q", line)
+	}
+
+	parts := strings.Split(line[:sep], ";")
+	prop := icalProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[sep+1:]}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	if prop.name == "BEGIN" || prop.name == "END" {
+		prop.value = strings.ToUpper(prop.value)
+	}
+	return prop, nil
+}
+
+func icalUID(id int) string {
+	return strconv.Itoa(id) + icalUIDSuffix
+}
+
+func parseICalUID(uid string) (int, bool) {
+	number, ok := strings.CutSuffix(uid, icalUIDSuffix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(number)
+	return id, err == nil && id > 0
+}
+
+func formatICalTime(t time.Time) string {
+	return t.UTC().Format(icalTimeLayout)
+}
+
+// parseICalTime accepts UTC date-times, floating or TZID local date-times and dates
+func parseICalTime(value string, params map[string]string) (time.Time, error) {
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse(icalTimeLayout, value)
+	case params["VALUE"] == "DATE" || len(value) == len("20060102"):
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// icalPriority maps priorities onto the 1 (highest) to 9 (lowest) scale of RFC 5545
+func icalPriority(p Priority) int {
+	switch p {
+	case PriorityHigh:
+		return 1
+	case PriorityMedium:
+		return 5
+	case PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+func priorityFromICal(p int) Priority {
+	switch {
+	case p >= 1 && p <= 4:
+		return PriorityHigh
+	case p == 5:
+		return PriorityMedium
+	case p >= 6 && p <= 9:
+		return PriorityLow
+	}
+	return PriorityNone
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitICalList splits a comma separated value, ignoring escaped commas
+func splitICalList(s string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// formatICalDuration formats d as an RFC 5545 duration such as "-PT1H30M"
+func formatICalDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+	days := d / (24 * time.Hour)
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 || days == 0 {
+		b.WriteByte('T')
+		h, m, s := d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second
+		if h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s > 0 || (h == 0 && m == 0) {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+var errICalDuration = errors.New("malformed duration")
+
+// parseICalDuration parses an RFC 5545 duration such as "-P1DT2H" or "PT15M"
+func parseICalDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, errICalDuration
+	}
+	s = s[1:]
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var total time.Duration
+	for s != "" {
+		if s[0] == 'T' {
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, errICalDuration
+		}
+		unit, ok := units[s[i]]
+		if !ok {
+			return 0, errICalDuration
+		}
+		n, _ := strconv.Atoi(s[:i])
+		total += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	return sign * total, nil
+}
@@ -0,0 +1,269 @@
+package taskmanager
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base unit a recurring task repeats in
+type Frequency int
+
+// Supported frequencies
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+)
+
+// String returns the RRULE name of the frequency
+func (f Frequency) String() string {
+	switch f {
+	case Daily:
+		return "DAILY"
+	case Weekly:
+		return "WEEKLY"
+	case Monthly:
+		return "MONTHLY"
+	}
+	return "UNKNOWN"
+}
+
+// Recurrence describes how a task repeats, it covers the FREQ, INTERVAL,
+// BYDAY, COUNT and UNTIL parts of an iCalendar RRULE
+type Recurrence struct {
+	Freq Frequency
+	// Interval repeats every Interval units of Freq, zero means 1
+	Interval int
+	// Weekdays restricts weekly rules to these days, empty means the weekday of the due date
+	Weekdays []time.Weekday
+	// Count is the number of occurrences left including the current one, zero means unlimited
+	Count int
+	// Until stops the rule after this time, zero means no end
+	Until time.Time
+}
+
+// maxRecurrenceSteps bounds the search for a month that has the day of month
+// of a monthly rule
+const maxRecurrenceSteps = 1000
+
+// Validate checks that the rule can produce occurrences
+func (r Recurrence) Validate() error {
+	if r.Freq < Daily || r.Freq > Monthly {
+		return fmt.Errorf("%w: unknown frequency", ErrInvalidRecurrence)
+	}
+	if r.Interval < 0 || r.Count < 0 {
+		return fmt.Errorf("%w: interval and count cannot be negative", ErrInvalidRecurrence)
+	}
+	if len(r.Weekdays) > 0 && r.Freq != Weekly {
+		return fmt.Errorf("%w: weekdays are only supported for weekly rules", ErrInvalidRecurrence)
+	}
+	for _, day := range r.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("%w: invalid weekday %d", ErrInvalidRecurrence, day)
+		}
+	}
+	return nil
+}
+
+// Next returns the first occurrence strictly after from, false when the rule
+// has no further occurrences
+func (r Recurrence) Next(from time.Time) (time.Time, bool) {
+	if r.Validate() != nil || r.Count == 1 {
+		return time.Time{}, false
+	}
+
+	interval := max(r.Interval, 1)
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = from.AddDate(0, 0, interval)
+	case Weekly:
+		next = r.nextWeekly(from, interval)
+	case Monthly:
+		next = nextMonthly(from, interval)
+	}
+	if next.IsZero() || (!r.Until.IsZero() && next.After(r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextWeekly walks forward day by day, accepting days in weeks that are a
+// multiple of interval away from the week of from (weeks start on Monday)
+func (r Recurrence) nextWeekly(from time.Time, interval int) time.Time {
+	if len(r.Weekdays) == 0 {
+		return from.AddDate(0, 0, 7*interval)
+	}
+	start := weekStart(from)
+	for i := 1; i <= 7*interval+7; i++ {
+		day := from.AddDate(0, 0, i)
+		weeks := int(weekStart(day).Sub(start).Hours()/24+0.5) / 7
+		if weeks%interval == 0 && slices.Contains(r.Weekdays, day.Weekday()) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+// weekStart returns midnight of the Monday starting the week of t
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// nextMonthly keeps the day of month of from, skipping months that lack it
+func nextMonthly(from time.Time, interval int) time.Time {
+	y, m, d := from.Date()
+	hh, mm, ss := from.Clock()
+	for i := 1; i <= maxRecurrenceSteps; i++ {
+		next := time.Date(y, m+time.Month(i*interval), d, hh, mm, ss, from.Nanosecond(), from.Location())
+		if next.Day() == d {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// clone returns a copy that shares no memory with r
+func (r Recurrence) clone() Recurrence {
+	r.Weekdays = slices.Clone(r.Weekdays)
+	return r
+}
+
+// nextOccurrence builds the task that follows t once t is completed, the new
+// task is due at the next occurrence after the current due date (or the
+// completion time when the task has no due date)
+func (t Task) nextOccurrence() (Task, bool) {
+	if t.Recurrence == nil {
+		return Task{}, false
+	}
+	anchor := t.CreatedAt
+	switch {
+	case t.DueDate != nil:
+		anchor = *t.DueDate
+	case t.CompletedAt != nil:
+		anchor = *t.CompletedAt
+	}
+	due, ok := t.Recurrence.Next(anchor)
+	if !ok {
+		return Task{}, false
+	}
+
+	next := t.clone()
+	next.ID = 0
+	next.Done = false
+	next.CompletedAt = nil
+	next.DueDate = &due
+	if next.Recurrence.Count > 0 {
+		next.Recurrence.Count--
+	}
+	return next, true
+}
+
+var rruleDays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// rruleUntilLayout is the UTC date-time form used by UNTIL
+const rruleUntilLayout = "20060102T150405Z"
+
+// String formats the rule as an RRULE value, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		days := make([]string, len(r.Weekdays))
+		for i, day := range r.Weekdays {
+			days[i] = rruleDays[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(rruleUntilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// ParseRRule parses the supported subset of an iCalendar RRULE value, an
+// optional "RRULE:" prefix is accepted
+func ParseRRule(s string) (Recurrence, error) {
+	var r Recurrence
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("%w: malformed part %q", ErrInvalidRecurrence, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch strings.ToUpper(value) {
+			case "DAILY":
+				r.Freq = Daily
+			case "WEEKLY":
+				r.Freq = Weekly
+			case "MONTHLY":
+				r.Freq = Monthly
+			default:
+				return Recurrence{}, fmt.Errorf("%w: unsupported frequency %q", ErrInvalidRecurrence, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = parseRRuleUntil(value)
+		case "BYDAY":
+			for _, name := range strings.Split(value, ",") {
+				day := slices.Index(rruleDays, strings.ToUpper(name))
+				if day < 0 {
+					return Recurrence{}, fmt.Errorf("%w: unsupported weekday %q", ErrInvalidRecurrence, name)
+				}
+				r.Weekdays = append(r.Weekdays, time.Weekday(day))
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return Recurrence{}, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRecurrence)
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("%w: unsupported part %q", ErrInvalidRecurrence, key)
+		}
+		if err != nil {
+			return Recurrence{}, fmt.Errorf("%w: %s: %v", ErrInvalidRecurrence, key, err)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return Recurrence{}, err
+	}
+	return r, nil
+}
+
+// parseRRuleUntil accepts UNTIL as a UTC date-time or a plain date
+func parseRRuleUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(rruleUntilLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("20060102", value)
+}
+
+// MarshalText encodes the rule as an RRULE value
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes an RRULE value
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	rule, err := ParseRRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
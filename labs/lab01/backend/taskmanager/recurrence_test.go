@@ -0,0 +1,127 @@
+package taskmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	// 2025-06-02 is a Monday
+	monday := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		rule   Recurrence
+		from   time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"daily", Recurrence{Freq: Daily}, monday, monday.AddDate(0, 0, 1), true},
+		{"every 3 days", Recurrence{Freq: Daily, Interval: 3}, monday, monday.AddDate(0, 0, 3), true},
+		{"weekly same weekday", Recurrence{Freq: Weekly}, monday, monday.AddDate(0, 0, 7), true},
+		{"weekly on wednesday and friday", Recurrence{Freq: Weekly, Weekdays: []time.Weekday{time.Wednesday, time.Friday}}, monday, monday.AddDate(0, 0, 2), true},
+		{"weekly wraps to next week", Recurrence{Freq: Weekly, Weekdays: []time.Weekday{time.Monday, time.Wednesday}}, monday.AddDate(0, 0, 2), monday.AddDate(0, 0, 7), true},
+		{"biweekly skips a week", Recurrence{Freq: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Monday}}, monday, monday.AddDate(0, 0, 14), true},
+		{"biweekly sunday ends the week", Recurrence{Freq: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Sunday}}, monday, monday.AddDate(0, 0, 6), true},
+		{"monthly", Recurrence{Freq: Monthly}, monday, monday.AddDate(0, 1, 0), true},
+		{"monthly skips short months", Recurrence{Freq: Monthly}, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"count exhausted", Recurrence{Freq: Daily, Count: 1}, monday, time.Time{}, false},
+		{"until reached", Recurrence{Freq: Daily, Until: monday.Add(time.Hour)}, monday, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rule.Next(tt.from)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	rule, err := ParseRRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=4;UNTIL=20251231T235959Z")
+	if err != nil {
+		t.Fatalf("ParseRRule failed: %v", err)
+	}
+	if rule.Freq != Weekly || rule.Interval != 2 || rule.Count != 4 || len(rule.Weekdays) != 2 ||
+		rule.Weekdays[0] != time.Monday || rule.Weekdays[1] != time.Friday ||
+		!rule.Until.Equal(time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+	if got := rule.String(); got != "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=4;UNTIL=20251231T235959Z" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, bad := range []string{"", "FREQ=YEARLY", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;INTERVAL=x", "FREQ=DAILY;BYHOUR=9"} {
+		if _, err := ParseRRule(bad); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("ParseRRule(%q) error = %v, want ErrInvalidRecurrence", bad, err)
+		}
+	}
+}
+
+func TestRecurrenceJSON(t *testing.T) {
+	due := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	task := Task{ID: 1, Title: "Standup", DueDate: &due, Recurrence: &Recurrence{Freq: Daily, Interval: 2}}
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Task
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Recurrence == nil || decoded.Recurrence.Freq != Daily || decoded.Recurrence.Interval != 2 {
+		t.Errorf("recurrence lost in JSON round trip: %s", data)
+	}
+}
+
+func TestCompletingRecurringTaskSpawnsNext(t *testing.T) {
+	tm := NewTaskManager()
+	due := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	task, err := tm.AddTask("Water plants", "", WithDueDate(due), WithTags("home"),
+		WithRecurrence(Recurrence{Freq: Weekly, Count: 2}), WithReminders(time.Hour))
+	if err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+
+	if err := tm.UpdateTask(task.ID, task.Title, "", true); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	open := false
+	pending := tm.ListTasks(&open)
+	if len(pending) != 1 {
+		t.Fatalf("expected one spawned task, got %d", len(pending))
+	}
+	next := pending[0]
+	if next.ID == task.ID || next.Title != task.Title || !next.HasTag("home") || len(next.Reminders) != 1 {
+		t.Errorf("spawned task does not copy the original: %+v", next)
+	}
+	if next.DueDate == nil || !next.DueDate.Equal(due.AddDate(0, 0, 7)) {
+		t.Errorf("spawned task due at %v, want %v", next.DueDate, due.AddDate(0, 0, 7))
+	}
+	if next.Recurrence == nil || next.Recurrence.Count != 1 {
+		t.Errorf("expected remaining count 1, got %+v", next.Recurrence)
+	}
+
+	// Re-saving an already done task must not spawn again
+	tm.UpdateTask(task.ID, "Water plants", "", true)
+	// The last occurrence does not spawn another one
+	tm.UpdateTask(next.ID, next.Title, "", true)
+	if got := len(tm.ListTasks(&open)); got != 0 {
+		t.Errorf("expected no open tasks after the series ended, got %d", got)
+	}
+	if got := len(tm.ListTasks(nil)); got != 2 {
+		t.Errorf("expected 2 tasks in total, got %d", got)
+	}
+}
+
+func TestInvalidRecurrenceRejected(t *testing.T) {
+	tm := NewTaskManager()
+	_, err := tm.AddTask("Task", "", WithRecurrence(Recurrence{Freq: Monthly, Weekdays: []time.Weekday{time.Monday}}))
+	if !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("expected ErrInvalidRecurrence, got %v", err)
+	}
+}
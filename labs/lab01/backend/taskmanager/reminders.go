@@ -0,0 +1,134 @@
+package taskmanager
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time, replacing it lets tests control time deterministically
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the Clock backed by the time package
+var SystemClock Clock = systemClock{}
+
+// Reminder is passed to the scheduler callback when a reminder is due
+type Reminder struct {
+	Task Task
+	// Before is the reminder offset from the task due date
+	Before time.Duration
+	// At is the time the reminder was scheduled for
+	At time.Time
+}
+
+// reminderKey identifies one reminder of one occurrence of a task
+type reminderKey struct {
+	taskID int
+	due    int64
+	before time.Duration
+}
+
+// ReminderScheduler fires a callback for every reminder offset of open tasks
+// with a due date. Reminders scheduled before the scheduler was created are
+// skipped, each remaining reminder fires exactly once.
+type ReminderScheduler struct {
+	tm     *TaskManager
+	clock  Clock
+	notify func(Reminder)
+
+	mu    sync.Mutex
+	since time.Time
+	fired map[reminderKey]bool
+}
+
+// NewReminderScheduler creates a scheduler for the tasks of tm that calls notify for due reminders
+func NewReminderScheduler(tm *TaskManager, clock Clock, notify func(Reminder)) *ReminderScheduler {
+	return &ReminderScheduler{
+		tm:     tm,
+		clock:  clock,
+		notify: notify,
+		since:  clock.Now(),
+		fired:  make(map[reminderKey]bool),
+	}
+}
+
+// Check fires every reminder that is due at the current clock time and
+// returns the time of the next pending reminder, false if there is none
+func (s *ReminderScheduler) Check() (time.Time, bool) {
+	s.mu.Lock()
+
+	now := s.clock.Now()
+	open := false
+	var due []Reminder
+	var next time.Time
+	seen := make(map[reminderKey]bool)
+
+	for _, task := range s.tm.FilterTasks(TaskFilter{Done: &open}) {
+		if task.DueDate == nil {
+			continue
+		}
+		for _, before := range task.Reminders {
+			at := task.DueDate.Add(-before)
+			key := reminderKey{taskID: task.ID, due: task.DueDate.UnixNano(), before: before}
+			seen[key] = true
+			switch {
+			case s.fired[key] || at.Before(s.since):
+			case !at.After(now):
+				s.fired[key] = true
+				due = append(due, Reminder{Task: task, Before: before, At: at})
+			case next.IsZero() || at.Before(next):
+				next = at
+			}
+		}
+	}
+
+	// Forget reminders of tasks that were completed, deleted or rescheduled
+	for key := range s.fired {
+		if !seen[key] {
+			delete(s.fired, key)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	for _, reminder := range due {
+		s.notify(reminder)
+	}
+	return next, !next.IsZero()
+}
+
+// Run checks reminders until ctx is cancelled, waking up when the next
+// reminder is due or when tasks change
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	events, cancel := s.tm.Subscribe()
+	defer func() { cancel() }()
+
+	for {
+		var timer <-chan time.Time
+		if next, ok := s.Check(); ok {
+			timer = s.clock.After(next.Sub(s.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer:
+		case _, ok := <-events:
+			if !ok {
+				// dropped for falling behind, the next Check catches up
+				cancel()
+				events, cancel = s.tm.Subscribe()
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package taskmanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) waiterCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func TestReminderSchedulerCheck(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	tm := NewTaskManager()
+	tm.SetClock(clock)
+
+	var fired []Reminder
+	s := NewReminderScheduler(tm, clock, func(r Reminder) { fired = append(fired, r) })
+
+	due := start.Add(2 * time.Hour)
+	task, _ := tm.AddTask("Meeting", "", WithDueDate(due), WithReminders(time.Hour, 15*time.Minute))
+	// Reminder already in the past when the scheduler started is skipped
+	tm.AddTask("Late", "", WithDueDate(start.Add(10*time.Minute)), WithReminders(time.Hour))
+
+	next, ok := s.Check()
+	if !ok || !next.Equal(due.Add(-time.Hour)) {
+		t.Fatalf("next reminder = %v, %v; want %v", next, ok, due.Add(-time.Hour))
+	}
+	if len(fired) != 0 {
+		t.Fatalf("no reminder should fire yet, got %v", fired)
+	}
+
+	clock.Advance(time.Hour)
+	next, ok = s.Check()
+	if len(fired) != 1 || fired[0].Task.ID != task.ID || fired[0].Before != time.Hour {
+		t.Fatalf("expected the one hour reminder, got %+v", fired)
+	}
+	if !ok || !next.Equal(due.Add(-15*time.Minute)) {
+		t.Errorf("next reminder = %v, %v; want %v", next, ok, due.Add(-15*time.Minute))
+	}
+
+	// Checking again does not repeat reminders
+	s.Check()
+	if len(fired) != 1 {
+		t.Errorf("reminder fired twice: %+v", fired)
+	}
+
+	// Completing the task cancels the remaining reminder
+	tm.UpdateTask(task.ID, task.Title, "", true)
+	clock.Advance(time.Hour)
+	if _, ok := s.Check(); ok || len(fired) != 1 {
+		t.Errorf("reminder fired for a done task: %+v", fired)
+	}
+}
+
+func TestReminderSchedulerRun(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	tm := NewTaskManager()
+	tm.SetClock(clock)
+
+	fired := make(chan Reminder, 1)
+	s := NewReminderScheduler(tm, clock, func(r Reminder) { fired <- r })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	tm.AddTask("Call", "", WithDueDate(start.Add(time.Hour)), WithReminders(10*time.Minute))
+
+	// Wait for the scheduler to pick up the task and arm its timer
+	deadline := time.Now().Add(time.Second)
+	for clock.waiterCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler did not arm a timer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(50 * time.Minute)
+	select {
+	case r := <-fired:
+		if r.Task.Title != "Call" || !r.At.Equal(start.Add(50*time.Minute)) {
+			t.Errorf("unexpected reminder: %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reminder did not fire")
+	}
+}
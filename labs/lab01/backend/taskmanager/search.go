@@ -0,0 +1,177 @@
+package taskmanager
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Ranking weights, a title match counts more than a description match and a
+// whole word more than a prefix of it
+const (
+	titleWeight       = 2.0
+	descriptionWeight = 1.0
+	prefixWeight      = 0.5
+)
+
+// SearchResult is a task matched by Search together with its relevance
+type SearchResult struct {
+	Task  Task
+	Score float64
+}
+
+// Search returns the tasks whose title or description contain every word of
+// query, best matches first. Matching ignores case and each query word also
+// matches longer words it is a prefix of, so "rep" finds "report". Returns an
+// empty slice if the query has no words or nothing matches.
+func (tm *TaskManager) Search(query string) []SearchResult {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	scores := tm.index.search(tokenize(query))
+	result := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		result = append(result, SearchResult{Task: tm.tasks[id].clone(), Score: score})
+	}
+	slices.SortFunc(result, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Task.ID, b.Task.ID)
+	})
+	return result
+}
+
+// tokenize splits text into lower-case words made of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// termFreq counts the occurrences of a term in the fields of one task
+type termFreq struct {
+	title       int
+	description int
+}
+
+// searchIndex is an inverted index over task titles and descriptions, the
+// zero value is empty and callers serialize access with the TaskManager lock
+type searchIndex struct {
+	// postings maps each term to the tasks containing it
+	postings map[string]map[int]termFreq
+	// terms holds the keys of postings in sorted order for prefix lookups
+	terms []string
+	// docs holds the terms of each indexed task so it can be removed
+	docs map[int][]string
+}
+
+// add indexes task, which must not be indexed yet
+func (ix *searchIndex) add(task Task) {
+	if ix.postings == nil {
+		ix.postings = make(map[string]map[int]termFreq)
+		ix.docs = make(map[int][]string)
+	}
+
+	freqs := make(map[string]termFreq)
+	for _, term := range tokenize(task.Title) {
+		f := freqs[term]
+		f.title++
+		freqs[term] = f
+	}
+	for _, term := range tokenize(task.Description) {
+		f := freqs[term]
+		f.description++
+		freqs[term] = f
+	}
+
+	terms := make([]string, 0, len(freqs))
+	for term, f := range freqs {
+		posting, ok := ix.postings[term]
+		if !ok {
+			posting = make(map[int]termFreq)
+			ix.postings[term] = posting
+			i, _ := slices.BinarySearch(ix.terms, term)
+			ix.terms = slices.Insert(ix.terms, i, term)
+		}
+		posting[task.ID] = f
+		terms = append(terms, term)
+	}
+	ix.docs[task.ID] = terms
+}
+
+// remove drops task id from the index, it is a no-op for unknown IDs
+func (ix *searchIndex) remove(id int) {
+	for _, term := range ix.docs[id] {
+		posting := ix.postings[term]
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(ix.postings, term)
+			if i, ok := slices.BinarySearch(ix.terms, term); ok {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// update reindexes a task whose title or description may have changed
+func (ix *searchIndex) update(current, task Task) {
+	if current.Title == task.Title && current.Description == task.Description {
+		return
+	}
+	ix.remove(current.ID)
+	ix.add(task)
+}
+
+// search scores the tasks matching every query word, the score of a word is
+// the best tf-idf weight among the terms it matches
+func (ix *searchIndex) search(words []string) map[int]float64 {
+	if len(words) == 0 {
+		return nil
+	}
+
+	var scores map[int]float64
+	for _, word := range words {
+		matched := ix.match(word)
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for id := range scores {
+			if s, ok := matched[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+		if len(scores) == 0 {
+			break
+		}
+	}
+	return scores
+}
+
+// match scores the tasks containing a term that starts with word
+func (ix *searchIndex) match(word string) map[int]float64 {
+	scores := make(map[int]float64)
+	total := float64(len(ix.docs))
+	for i := sort.SearchStrings(ix.terms, word); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], word); i++ {
+		term := ix.terms[i]
+		posting := ix.postings[term]
+		weight := math.Log(1 + total/float64(len(posting)))
+		if term != word {
+			weight *= prefixWeight
+		}
+		for id, f := range posting {
+			s := weight * (titleWeight*float64(f.title) + descriptionWeight*float64(f.description))
+			if s > scores[id] {
+				scores[id] = s
+			}
+		}
+	}
+	return scores
+}
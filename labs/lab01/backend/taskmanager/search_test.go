@@ -0,0 +1,117 @@
+package taskmanager
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Write the Q3 report!", []string{"write", "the", "q3", "report"}},
+		{"e-mail, follow_up", []string{"e", "mail", "follow", "up"}},
+		{"Überprüfung ÄNDERN", []string{"überprüfung", "ändern"}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tm := NewTaskManager()
+	report, _ := tm.AddTask("Quarterly report", "Collect numbers for finance")
+	reporting, _ := tm.AddTask("Fix reporting bug", "")
+	mention, _ := tm.AddTask("Call the bank", "Ask about the report they sent")
+	tm.AddTask("Buy milk", "")
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		// Title matches rank above description matches, whole words above prefixes
+		{"report", []int{report.ID, reporting.ID, mention.ID}},
+		{"REPORT finance", []int{report.ID}},
+		// Both are prefixes here, so the rarer term wins
+		{"rep", []int{reporting.ID, report.ID, mention.ID}},
+		{"report milk", nil},
+		{"  ", nil},
+		{"unknown", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := tm.Search(tt.query)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results %+v, want IDs %v", len(got), got, tt.want)
+			}
+			for i, id := range tt.want {
+				if got[i].Task.ID != id {
+					t.Errorf("position %d: got task %d (%s), want %d", i, got[i].Task.ID, got[i].Task.Title, id)
+				}
+				if i > 0 && got[i].Score > got[i-1].Score {
+					t.Errorf("results not ordered by score: %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchIndexUpdates(t *testing.T) {
+	tm := NewTaskManager()
+	task, _ := tm.AddTask("Draft proposal", "")
+
+	if err := tm.UpdateTask(task.ID, "Send invoice", "", false); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if got := tm.Search("proposal"); len(got) != 0 {
+		t.Errorf("stale terms still indexed: %+v", got)
+	}
+	if got := tm.Search("invoice"); len(got) != 1 || got[0].Task.Title != "Send invoice" {
+		t.Errorf("new title not indexed: %+v", got)
+	}
+
+	tm.DeleteTask(task.ID)
+	if got := tm.Search("invoice"); len(got) != 0 {
+		t.Errorf("deleted task still found: %+v", got)
+	}
+	if len(tm.index.terms) != 0 || len(tm.index.postings) != 0 || len(tm.index.docs) != 0 {
+		t.Errorf("index not emptied: %+v", tm.index)
+	}
+
+	tm.Undo()
+	if got := tm.Search("invoice"); len(got) != 1 {
+		t.Errorf("restored task not indexed: %+v", got)
+	}
+}
+
+func TestSearchLoadedFromStore(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(Task{ID: 3, Title: "Renew passport"})
+
+	tm, err := NewTaskManagerWithStore(store)
+	if err != nil {
+		t.Fatalf("NewTaskManagerWithStore failed: %v", err)
+	}
+	if got := tm.Search("pass"); len(got) != 1 || got[0].Task.ID != 3 {
+		t.Errorf("loaded tasks not indexed: %+v", got)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	words := []string{"review", "report", "deploy", "design", "meeting", "invoice", "backup", "release", "customer", "budget"}
+	tm := NewTaskManager()
+	for i := range 20000 {
+		title := fmt.Sprintf("%s %s %d", words[i%len(words)], words[(i/len(words))%len(words)], i)
+		tm.AddTask(title, "Notes about the "+words[(i*7)%len(words)])
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		tm.Search("rep deploy")
+	}
+}
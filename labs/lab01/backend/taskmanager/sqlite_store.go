@@ -0,0 +1,266 @@
+package taskmanager
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteColumns lists the columns added to the tasks table after it was first
+// created, they are appended to databases written by older versions on open
+var sqliteColumns = []struct {
+	name string
+	decl string
+}{
+	{"due_date", "TEXT"},
+	{"priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"tags", "TEXT NOT NULL DEFAULT '[]'"},
+	{"completed_at", "TEXT"},
+	{"recurrence", "TEXT"},
+	{"reminders", "TEXT NOT NULL DEFAULT '[]'"},
+	{"parent_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"blocked_by", "TEXT NOT NULL DEFAULT '[]'"},
+}
+
+// SQLiteStore keeps tasks in a SQLite database table
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path and prepares the tasks table
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// migrateSQLite creates the tasks table and adds any missing columns
+func migrateSQLite(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		done BOOLEAN NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	// counters keeps next_id, one more than the highest task ID ever saved
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS counters (
+		name TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('tasks')`)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, col := range sqliteColumns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE tasks ADD COLUMN %s %s`, col.name, col.decl)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load returns every stored task ordered by ID
+func (s *SQLiteStore) Load() ([]Task, error) {
+	rows, err := s.db.Query(`SELECT id, title, description, done, created_at,
+		due_date, priority, tags, completed_at, recurrence, reminders,
+		parent_id, blocked_by FROM tasks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]Task, 0)
+	for rows.Next() {
+		var task Task
+		var createdAt, tags, reminders, blockedBy string
+		var dueDate, completedAt, recurrence sql.NullString
+		err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Done, &createdAt,
+			&dueDate, &task.Priority, &tags, &completedAt, &recurrence, &reminders,
+			&task.ParentID, &blockedBy)
+		if err != nil {
+			return nil, err
+		}
+		if task.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, err
+		}
+		if task.DueDate, err = parseNullTime(dueDate); err != nil {
+			return nil, err
+		}
+		if task.CompletedAt, err = parseNullTime(completedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &task.Tags); err != nil {
+			return nil, err
+		}
+		if len(task.Tags) == 0 {
+			task.Tags = nil
+		}
+		if recurrence.Valid {
+			rule, err := ParseRRule(recurrence.String)
+			if err != nil {
+				return nil, err
+			}
+			task.Recurrence = &rule
+		}
+		if err := json.Unmarshal([]byte(reminders), &task.Reminders); err != nil {
+			return nil, err
+		}
+		if len(task.Reminders) == 0 {
+			task.Reminders = nil
+		}
+		if err := json.Unmarshal([]byte(blockedBy), &task.BlockedBy); err != nil {
+			return nil, err
+		}
+		if len(task.BlockedBy) == 0 {
+			task.BlockedBy = nil
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// Save inserts or replaces a task
+func (s *SQLiteStore) Save(task Task) error {
+	tags, err := marshalList(task.Tags)
+	if err != nil {
+		return err
+	}
+	reminders, err := marshalList(task.Reminders)
+	if err != nil {
+		return err
+	}
+	blockedBy, err := marshalList(task.BlockedBy)
+	if err != nil {
+		return err
+	}
+	var recurrence sql.NullString
+	if task.Recurrence != nil {
+		recurrence = sql.NullString{String: task.Recurrence.String(), Valid: true}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO tasks (id, title, description, done, created_at,
+			due_date, priority, tags, completed_at, recurrence, reminders,
+			parent_id, blocked_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title,
+			description = excluded.description,
+			done = excluded.done,
+			created_at = excluded.created_at,
+			due_date = excluded.due_date,
+			priority = excluded.priority,
+			tags = excluded.tags,
+			completed_at = excluded.completed_at,
+			recurrence = excluded.recurrence,
+			reminders = excluded.reminders,
+			parent_id = excluded.parent_id,
+			blocked_by = excluded.blocked_by`,
+		task.ID, task.Title, task.Description, task.Done, task.CreatedAt.Format(time.RFC3339Nano),
+		formatNullTime(task.DueDate), task.Priority, tags, formatNullTime(task.CompletedAt),
+		recurrence, reminders, task.ParentID, blockedBy)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO counters (name, value) VALUES ('next_id', ?)
+		ON CONFLICT(name) DO UPDATE SET value = max(value, excluded.value)`, task.ID+1)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NextID returns one more than the highest ID ever saved, deleted tasks
+// included. Databases written before the counter was kept return 1.
+func (s *SQLiteStore) NextID() (int, error) {
+	next := 1
+	err := s.db.QueryRow(`SELECT value FROM counters WHERE name = 'next_id'`).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 1, nil
+	}
+	return next, err
+}
+
+// Delete removes a task
+func (s *SQLiteStore) Delete(id int) error {
+	_, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	return err
+}
+
+// Close closes the underlying database connection
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// marshalList encodes a slice column as a JSON array, nil becomes an empty array
+func marshalList[T any](list []T) (string, error) {
+	if list == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+// formatNullTime encodes an optional time as RFC 3339 text or SQL NULL
+func formatNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+// parseNullTime decodes a column written by formatNullTime
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
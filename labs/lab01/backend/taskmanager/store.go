@@ -0,0 +1,221 @@
+package taskmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store persists tasks on behalf of a TaskManager
+type Store interface {
+	// Load returns every stored task
+	Load() ([]Task, error)
+	// Save inserts the task or replaces the stored task with the same ID
+	Save(task Task) error
+	// Delete removes the task with the given ID, deleting a missing task is not an error
+	Delete(id int) error
+}
+
+// IDStore is implemented by a Store that remembers the highest ID it ever
+// saved, so a restarted TaskManager never hands out the ID of a deleted task
+type IDStore interface {
+	Store
+	// NextID returns one more than the highest ID ever saved
+	NextID() (int, error)
+}
+
+// MemoryStore keeps tasks in memory only, nothing survives a restart
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tasks  map[int]Task
+	nextID int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[int]Task), nextID: 1}
+}
+
+// Load returns every stored task ordered by ID
+func (s *MemoryStore) Load() ([]Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedTasks(s.tasks), nil
+}
+
+// Save inserts or replaces a task
+func (s *MemoryStore) Save(task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[task.ID] = task
+	s.nextID = max(s.nextID, task.ID+1)
+	return nil
+}
+
+// NextID returns one more than the highest ID ever saved
+func (s *MemoryStore) NextID() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextID, nil
+}
+
+// Delete removes a task
+func (s *MemoryStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, id)
+	return nil
+}
+
+// FileStore keeps tasks in a single JSON file, every change rewrites the file atomically
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	tasks  map[int]Task
+	nextID int
+}
+
+// fileContents is the layout of the file of a FileStore
+type fileContents struct {
+	NextID int    `json:"next_id"`
+	Tasks  []Task `json:"tasks"`
+}
+
+// NewFileStore opens the JSON file at path, a missing file is treated as an
+// empty store. Files holding a bare array of tasks, as written before the
+// next ID was kept, are read as well.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, tasks: make(map[int]Task), nextID: 1}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var contents fileContents
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &contents.Tasks)
+	} else {
+		err = json.Unmarshal(data, &contents)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.nextID = max(s.nextID, contents.NextID)
+	for _, task := range contents.Tasks {
+		s.tasks[task.ID] = task
+		s.nextID = max(s.nextID, task.ID+1)
+	}
+	return s, nil
+}
+
+// Load returns every stored task ordered by ID
+func (s *FileStore) Load() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedTasks(s.tasks), nil
+}
+
+// Save inserts or replaces a task and writes the file
+func (s *FileStore) Save(task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.tasks[task.ID]
+	prevNext := s.nextID
+	s.tasks[task.ID] = task
+	s.nextID = max(s.nextID, task.ID+1)
+	if err := s.flush(); err != nil {
+		s.nextID = prevNext
+		if existed {
+			s.tasks[task.ID] = prev
+		} else {
+			delete(s.tasks, task.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a task and writes the file
+func (s *FileStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.tasks[id]
+	if !existed {
+		return nil
+	}
+	delete(s.tasks, id)
+	if err := s.flush(); err != nil {
+		s.tasks[id] = prev
+		return err
+	}
+	return nil
+}
+
+// NextID returns one more than the highest ID ever saved, deleted tasks included
+func (s *FileStore) NextID() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextID, nil
+}
+
+// flush writes all tasks to a temporary file, syncs it and renames it over the
+// store file so readers never observe a partially written file
+func (s *FileStore) flush() error {
+	data, err := json.MarshalIndent(fileContents{NextID: s.nextID, Tasks: sortedTasks(s.tasks)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory entry so a completed rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// sortedTasks copies the map into a slice ordered by ID
+func sortedTasks(tasks map[int]Task) []Task {
+	result := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, task)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
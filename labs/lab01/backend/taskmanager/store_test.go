@@ -0,0 +1,191 @@
+package taskmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoresSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{
+			name: "json file",
+			open: func(t *testing.T) Store {
+				s, err := NewFileStore(filepath.Join(dir, "tasks.json"))
+				if err != nil {
+					t.Fatalf("NewFileStore failed: %v", err)
+				}
+				return s
+			},
+		},
+		{
+			name: "sqlite",
+			open: func(t *testing.T) Store {
+				s, err := NewSQLiteStore(filepath.Join(dir, "tasks.db"))
+				if err != nil {
+					t.Fatalf("NewSQLiteStore failed: %v", err)
+				}
+				t.Cleanup(func() { s.Close() })
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := NewTaskManagerWithStore(tt.open(t))
+			if err != nil {
+				t.Fatalf("NewTaskManagerWithStore failed: %v", err)
+			}
+			due := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+			task1, _ := tm.AddTask("Task 1", "Description 1",
+				WithDueDate(due), WithPriority(PriorityHigh), WithTags("work", "urgent"))
+			task2, _ := tm.AddTask("Task 2", "Description 2", WithParent(task1.ID), WithBlockedBy(task1.ID))
+			task3, _ := tm.AddTask("Task 3", "Description 3")
+			if err := tm.UpdateTask(task1.ID, "Task 1 updated", "", true); err != nil {
+				t.Fatalf("UpdateTask failed: %v", err)
+			}
+			if err := tm.DeleteTask(task3.ID); err != nil {
+				t.Fatalf("DeleteTask failed: %v", err)
+			}
+
+			reopened, err := NewTaskManagerWithStore(tt.open(t))
+			if err != nil {
+				t.Fatalf("reopen failed: %v", err)
+			}
+
+			tasks := reopened.ListTasks(nil)
+			if len(tasks) != 2 {
+				t.Fatalf("expected 2 tasks after reload, got %d", len(tasks))
+			}
+			got, err := reopened.GetTask(task1.ID)
+			if err != nil {
+				t.Fatalf("GetTask failed: %v", err)
+			}
+			if got.Title != "Task 1 updated" || !got.Done {
+				t.Errorf("update not persisted: %+v", got)
+			}
+			if got.DueDate == nil || !got.DueDate.Equal(due) {
+				t.Errorf("due date not persisted: %v", got.DueDate)
+			}
+			if got.Priority != PriorityHigh || !got.HasTag("work") || !got.HasTag("urgent") {
+				t.Errorf("priority or tags not persisted: %+v", got)
+			}
+			if got.CompletedAt == nil {
+				t.Error("completed at not persisted")
+			}
+			if !got.CreatedAt.Equal(task1.CreatedAt) {
+				t.Errorf("CreatedAt changed: %v != %v", got.CreatedAt, task1.CreatedAt)
+			}
+			got2, err := reopened.GetTask(task2.ID)
+			if err != nil {
+				t.Errorf("task 2 missing after reload: %v", err)
+			}
+			if got2.ParentID != task1.ID || len(got2.BlockedBy) != 1 || got2.BlockedBy[0] != task1.ID {
+				t.Errorf("links not persisted: %+v", got2)
+			}
+			if _, err := reopened.GetTask(task3.ID); err != ErrTaskNotFound {
+				t.Errorf("deleted task came back: %v", err)
+			}
+
+			// the ID of the deleted highest task is never handed out again
+			next, err := reopened.AddTask("Task 4", "")
+			if err != nil {
+				t.Fatalf("AddTask failed: %v", err)
+			}
+			if next.ID != task3.ID+1 {
+				t.Errorf("expected next ID %d after deleted task %d, got %d", task3.ID+1, task3.ID, next.ID)
+			}
+		})
+	}
+}
+
+func TestFileStoreAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.json")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	if err := s.Save(Task{ID: 1, Title: "Task"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "tasks.json" {
+		t.Errorf("expected only tasks.json in directory, got %v", entries)
+	}
+}
+
+func TestFileStoreCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("expected error for corrupt file, got nil")
+	}
+}
+
+func TestSQLiteStoreMigratesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	// Recreate the table as the first version of the store wrote it
+	_, err = s.db.Exec(`DROP TABLE tasks;
+		CREATE TABLE tasks (
+			id INTEGER PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			done BOOLEAN NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		);
+		INSERT INTO tasks (id, title, created_at) VALUES (7, 'Old task', '2024-01-01T00:00:00Z');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != 7 || tasks[0].DueDate != nil || tasks[0].Tags != nil {
+		t.Errorf("unexpected tasks after migration: %+v", tasks)
+	}
+}
+
+func TestFileStoreReadsTaskArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte(`[{"id": 2, "title": "Task"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	tasks, _ := s.Load()
+	if len(tasks) != 1 || tasks[0].Title != "Task" {
+		t.Errorf("unexpected tasks %+v", tasks)
+	}
+	if next, _ := s.NextID(); next != 3 {
+		t.Errorf("expected next ID 3, got %d", next)
+	}
+}
@@ -2,62 +2,493 @@ package taskmanager
 
 import (
 	"errors"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Predefined errors
 var (
-	ErrTaskNotFound = errors.New("task not found")
-	ErrEmptyTitle   = errors.New("title cannot be empty")
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrInvalidReference  = errors.New("invalid task reference")
+	ErrEmptyTitle        = errors.New("title cannot be empty")
+	ErrInvalidPriority   = errors.New("invalid priority")
+	ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+	ErrBlocked           = errors.New("task is blocked by an open task")
+	ErrHasSubtasks       = errors.New("task has subtasks")
+	ErrDuplicateID       = errors.New("duplicate task id")
+	ErrUnknownFormat     = errors.New("unknown format")
 )
 
+// Priority ranks how urgent a task is, the zero value means no priority was set
+type Priority int
+
+// Supported priority levels
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// String returns the lower-case name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityNone:
+		return "none"
+	case PriorityLow:
+		return "low"
+	case PriorityMedium:
+		return "medium"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// Valid reports whether p is one of the supported priority levels
+func (p Priority) Valid() bool {
+	return p >= PriorityNone && p <= PriorityHigh
+}
+
 // Task represents a single task
 type Task struct {
-	ID          int
-	Title       string
-	Description string
-	Done        bool
-	CreatedAt   time.Time
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Done        bool       `json:"done"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Recurrence makes completing the task spawn its next occurrence
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// Reminders are offsets before DueDate at which a ReminderScheduler fires
+	Reminders []time.Duration `json:"reminders,omitempty"`
+	// ParentID is the ID of the task this one is a subtask of, zero for top-level tasks
+	ParentID int `json:"parent_id,omitempty"`
+	// BlockedBy lists the IDs of tasks that must be done before this one
+	BlockedBy []int `json:"blocked_by,omitempty"`
+}
+
+// HasTag reports whether the task carries the given tag, ignoring case
+func (t Task) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, normalizeTag(tag))
+}
+
+// IsOverdue reports whether the task is still open and its due date is before now
+func (t Task) IsOverdue(now time.Time) bool {
+	return !t.Done && t.DueDate != nil && t.DueDate.Before(now)
+}
+
+// clone returns a copy of the task that shares no memory with the original
+func (t Task) clone() Task {
+	t.Tags = slices.Clone(t.Tags)
+	if t.DueDate != nil {
+		due := *t.DueDate
+		t.DueDate = &due
+	}
+	if t.CompletedAt != nil {
+		completed := *t.CompletedAt
+		t.CompletedAt = &completed
+	}
+	if t.Recurrence != nil {
+		rule := t.Recurrence.clone()
+		t.Recurrence = &rule
+	}
+	t.Reminders = slices.Clone(t.Reminders)
+	t.BlockedBy = slices.Clone(t.BlockedBy)
+	return t
+}
+
+// validate checks the fields set through options
+func (t Task) validate() error {
+	if !t.Priority.Valid() {
+		return ErrInvalidPriority
+	}
+	if t.Recurrence != nil {
+		if err := t.Recurrence.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaskOption sets an optional field when adding or updating a task
+type TaskOption func(*Task)
+
+// WithDueDate sets the task due date
+func WithDueDate(due time.Time) TaskOption {
+	return func(t *Task) {
+		d := due
+		t.DueDate = &d
+	}
+}
+
+// WithoutDueDate clears the task due date
+func WithoutDueDate() TaskOption {
+	return func(t *Task) {
+		t.DueDate = nil
+	}
 }
 
-// TaskManager manages a collection of tasks
+// WithPriority sets the task priority
+func WithPriority(p Priority) TaskOption {
+	return func(t *Task) {
+		t.Priority = p
+	}
+}
+
+// WithTags replaces the task tags, tags are trimmed, lower-cased and de-duplicated
+func WithTags(tags ...string) TaskOption {
+	return func(t *Task) {
+		t.Tags = normalizeTags(tags)
+	}
+}
+
+// WithRecurrence makes the task repeat according to rule
+func WithRecurrence(rule Recurrence) TaskOption {
+	return func(t *Task) {
+		r := rule.clone()
+		t.Recurrence = &r
+	}
+}
+
+// WithoutRecurrence stops the task from repeating
+func WithoutRecurrence() TaskOption {
+	return func(t *Task) {
+		t.Recurrence = nil
+	}
+}
+
+// WithReminders replaces the reminder offsets, each one fires that long before the due date
+func WithReminders(before ...time.Duration) TaskOption {
+	return func(t *Task) {
+		t.Reminders = slices.Clone(before)
+	}
+}
+
+// WithParent makes the task a subtask of the task with the given ID, zero makes it top-level
+func WithParent(id int) TaskOption {
+	return func(t *Task) {
+		t.ParentID = id
+	}
+}
+
+// WithBlockedBy replaces the IDs of the tasks that must be done before this one
+func WithBlockedBy(ids ...int) TaskOption {
+	return func(t *Task) {
+		t.BlockedBy = normalizeIDs(ids)
+	}
+}
+
+// TaskManager manages a collection of tasks, it is safe for concurrent use
 type TaskManager struct {
-	tasks  map[int]Task
-	nextID int
+	mu      sync.RWMutex
+	tasks   map[int]Task
+	nextID  int
+	store   Store
+	clock   Clock
+	events  eventHub
+	history history
+	index   searchIndex
 }
 
 // NewTaskManager creates a new task manager
 func NewTaskManager() *TaskManager {
-	// TODO: Implement this function
-	return nil
+	return &TaskManager{
+		tasks:  make(map[int]Task),
+		nextID: 1,
+		store:  NewMemoryStore(),
+		clock:  SystemClock,
+	}
+}
+
+// NewTaskManagerWithStore creates a task manager backed by store, loading any
+// tasks it already holds and continuing IDs after the highest one found, or
+// after the highest one ever saved if store is an IDStore
+func NewTaskManagerWithStore(store Store) (*TaskManager, error) {
+	tasks, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	tm := &TaskManager{
+		tasks:  make(map[int]Task, len(tasks)),
+		nextID: 1,
+		store:  store,
+		clock:  SystemClock,
+	}
+	for _, task := range tasks {
+		tm.tasks[task.ID] = task
+		tm.index.add(task)
+		if task.ID >= tm.nextID {
+			tm.nextID = task.ID + 1
+		}
+	}
+	if ids, ok := store.(IDStore); ok {
+		next, err := ids.NextID()
+		if err != nil {
+			return nil, err
+		}
+		tm.nextID = max(tm.nextID, next)
+	}
+	return tm, nil
+}
+
+// SetClock replaces the clock used for timestamps and overdue checks
+func (tm *TaskManager) SetClock(clock Clock) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.clock = clock
+}
+
+// now returns the current time of the manager clock
+func (tm *TaskManager) now() time.Time {
+	return tm.clock.Now()
 }
 
 // AddTask adds a new task to the manager, returns an error if the title is empty, and increments the nextID
-func (tm *TaskManager) AddTask(title, description string) (Task, error) {
-	// TODO: Implement this function
-	return Task{}, nil
+func (tm *TaskManager) AddTask(title, description string, opts ...TaskOption) (Task, error) {
+	return tm.addTask("", title, description, opts...)
+}
+
+func (tm *TaskManager) addTask(actor, title, description string, opts ...TaskOption) (Task, error) {
+	if title == "" {
+		return Task{}, ErrEmptyTitle
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	defer tm.commitLocked(OpAdd, actor, tm.nextID)
+
+	task := Task{
+		Title:       title,
+		Description: description,
+	}
+	for _, opt := range opts {
+		opt(&task)
+	}
+	if err := task.validate(); err != nil {
+		return Task{}, err
+	}
+	if err := tm.checkLinksLocked(task); err != nil {
+		return Task{}, err
+	}
+	return tm.insertLocked(task)
+}
+
+// insertLocked assigns the next ID to task, stores it and publishes the event,
+// the caller must hold the write lock
+func (tm *TaskManager) insertLocked(task Task) (Task, error) {
+	task.ID = tm.nextID
+	task.CreatedAt = tm.now()
+	if err := tm.putLocked(task); err != nil {
+		return Task{}, err
+	}
+	return task.clone(), nil
+}
+
+// putLocked stores a new task under its own ID, moving nextID past it, and
+// publishes the event, the caller must hold the write lock
+func (tm *TaskManager) putLocked(task Task) error {
+	if err := tm.store.Save(task); err != nil {
+		return err
+	}
+
+	tm.tasks[task.ID] = task
+	tm.index.add(task)
+	if task.ID >= tm.nextID {
+		tm.nextID = task.ID + 1
+	}
+	tm.history.record(nil, &task)
+	tm.events.publish(TaskEvent{Type: EventCreated, Task: task.clone(), Time: tm.now()})
+	return nil
 }
 
 // UpdateTask updates an existing task, returns an error if the title is empty or the task is not found
-func (tm *TaskManager) UpdateTask(id int, title, description string, done bool) error {
-	// TODO: Implement this function
+func (tm *TaskManager) UpdateTask(id int, title, description string, done bool, opts ...TaskOption) error {
+	return tm.updateTask("", id, title, description, done, opts...)
+}
+
+func (tm *TaskManager) updateTask(actor string, id int, title, description string, done bool, opts ...TaskOption) error {
+	if title == "" {
+		return ErrEmptyTitle
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	defer tm.commitLocked(OpUpdate, actor, id)
+
+	current, ok := tm.tasks[id]
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	task := current.clone()
+	task.Title = title
+	task.Description = description
+	task.Done = done
+	for _, opt := range opts {
+		opt(&task)
+	}
+	if err := task.validate(); err != nil {
+		return err
+	}
+	if err := tm.checkLinksLocked(task); err != nil {
+		return err
+	}
+	completing := done && !current.Done
+	if completing && tm.isBlockedLocked(task) {
+		return ErrBlocked
+	}
+	switch {
+	case completing:
+		completed := tm.now()
+		task.CompletedAt = &completed
+	case !done:
+		task.CompletedAt = nil
+	}
+	if err := tm.replaceLocked(current, task); err != nil {
+		return err
+	}
+
+	if completing {
+		if next, ok := task.nextOccurrence(); ok {
+			if _, err := tm.insertLocked(next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceLocked stores task in place of current and publishes the event, the
+// caller must hold the write lock
+func (tm *TaskManager) replaceLocked(current, task Task) error {
+	if err := tm.store.Save(task); err != nil {
+		return err
+	}
+
+	tm.tasks[task.ID] = task
+	tm.index.update(current, task)
+	tm.history.record(&current, &task)
+	tm.events.publish(TaskEvent{Type: EventUpdated, Task: task.clone(), Previous: current.clone(), Time: tm.now()})
 	return nil
 }
 
+// DeleteOption changes how DeleteTask treats subtasks
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	cascade bool
+}
+
+// WithCascade makes DeleteTask remove all subtasks of the task as well,
+// without it deleting a task that has subtasks fails with ErrHasSubtasks
+func WithCascade() DeleteOption {
+	return func(o *deleteOptions) {
+		o.cascade = true
+	}
+}
+
 // DeleteTask removes a task from the manager, returns an error if the task is not found
-func (tm *TaskManager) DeleteTask(id int) error {
-	// TODO: Implement this function
+func (tm *TaskManager) DeleteTask(id int, opts ...DeleteOption) error {
+	return tm.deleteTask("", id, opts...)
+}
+
+func (tm *TaskManager) deleteTask(actor string, id int, opts ...DeleteOption) error {
+	var options deleteOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	defer tm.commitLocked(OpDelete, actor, id)
+
+	if _, ok := tm.tasks[id]; !ok {
+		return ErrTaskNotFound
+	}
+	if !options.cascade && len(tm.childrenLocked(id)) > 0 {
+		return ErrHasSubtasks
+	}
+
+	removed := tm.subtreeLocked(id)
+	for _, taskID := range removed {
+		if err := tm.removeLocked(taskID); err != nil {
+			return err
+		}
+	}
+	return tm.unblockLocked(removed)
+}
+
+// removeLocked deletes a single task and publishes the event, the caller must
+// hold the write lock
+func (tm *TaskManager) removeLocked(id int) error {
+	task := tm.tasks[id]
+	if err := tm.store.Delete(id); err != nil {
+		return err
+	}
+
+	delete(tm.tasks, id)
+	tm.index.remove(id)
+	tm.history.record(&task, nil)
+	tm.events.publish(TaskEvent{Type: EventDeleted, Task: task.clone(), Time: tm.now()})
 	return nil
 }
 
 // GetTask retrieves a task by ID, returns an error if the task is not found
 func (tm *TaskManager) GetTask(id int) (Task, error) {
-	// TODO: Implement this function
-	return Task{}, nil
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	task, ok := tm.tasks[id]
+	if !ok {
+		return Task{}, ErrTaskNotFound
+	}
+	return task.clone(), nil
 }
 
-// ListTasks returns all tasks, optionally filtered by done status, returns an empty slice if no tasks are found
-func (tm *TaskManager) ListTasks(filterDone *bool) []Task {
-	// TODO: Implement this function
-	return nil
+// ListTasks returns all tasks, optionally filtered by done status and opts,
+// returns an empty slice if no tasks are found
+func (tm *TaskManager) ListTasks(filterDone *bool, opts ...ListOption) []Task {
+	filter := TaskFilter{Done: filterDone}
+	for _, opt := range opts {
+		opt(&filter)
+	}
+	return tm.FilterTasks(filter)
+}
+
+// normalizeIDs sorts ids and drops zero and duplicate values
+func normalizeIDs(ids []int) []int {
+	var result []int
+	for _, id := range ids {
+		if id != 0 && !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// normalizeTag trims and lower-cases a tag
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes every tag, dropping empty and duplicate ones
+func normalizeTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(result, tag) {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result
 }
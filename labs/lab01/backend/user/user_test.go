@@ -1,7 +1,13 @@
 package user
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+
+	"shared/emailaddr"
+	"shared/personname"
 )
 
 func TestNewUser(t *testing.T) {
@@ -224,3 +230,123 @@ func TestUserString(t *testing.T) {
 		})
 	}
 }
+
+func TestUserValidateAll(t *testing.T) {
+	valid := User{Name: "John Doe", Age: 30, Email: "john@example.com"}
+	if err := valid.ValidateAll(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	user := User{Name: "", Age: 200, Email: "john@notvalid"}
+	err := user.ValidateAll()
+	for _, want := range []error{ErrInvalidName, ErrInvalidAge, ErrInvalidEmail} {
+		if !errors.Is(err, want) {
+			t.Errorf("Expected %v in %v", want, err)
+		}
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("Expected 3 field errors, got %v", err)
+	}
+	if errs.Field("age") != ErrInvalidAge || errs.Field("unknown") != nil {
+		t.Errorf("Field lookup failed: %v", errs)
+	}
+	if err := user.Validate(); err != ErrInvalidName {
+		t.Errorf("Validate should return the first error, got %v", err)
+	}
+
+	partial := User{Name: "John Doe", Age: -1, Email: "bad"}
+	if err := partial.ValidateAll(); errors.Is(err, ErrInvalidName) || !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("Unexpected errors: %v", err)
+	}
+}
+
+func TestValidationErrorsJSON(t *testing.T) {
+	user := User{Name: "John Doe", Age: -1, Email: "bad"}
+	data, err := json.Marshal(user.ValidateAll())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := map[string]string{"age": ErrInvalidAge.Error(), "email": ErrInvalidEmail.Error()}
+	if len(fields) != len(want) {
+		t.Fatalf("Expected %v, got %s", want, data)
+	}
+	for field, message := range want {
+		if fields[field] != message {
+			t.Errorf("Field %s: expected %q, got %q", field, message, fields[field])
+		}
+	}
+}
+
+func TestIsValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"john@example.com", true},
+		{`"john doe"@example.com`, true},
+		{"jose@bücher.example", true},
+		{"john@notvalid", false},
+		{"john..doe@example.com", false},
+		{"john@mailinator.com", true},
+	}
+	for _, tt := range tests {
+		if got := IsValidEmail(tt.email); got != tt.want {
+			t.Errorf("IsValidEmail(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+
+	SetEmailValidator(emailaddr.NewValidator(emailaddr.WithDisposableBlocklist()))
+	defer SetEmailValidator(nil)
+	if IsValidEmail("john@mailinator.com") {
+		t.Error("Expected disposable domain to be rejected")
+	}
+	if !IsValidEmail("john@example.com") {
+		t.Error("Expected regular domain to be accepted")
+	}
+}
+
+func TestIsValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"John Doe", true},
+		{"Александра Константиновна", true},
+		{"José María", true},
+		{"", false},
+		{"   ", false},
+		{"Иван Smith", true},
+		{"Ivanов", false},
+		{"John\u200bDoe", false},
+		{strings.Repeat("Ж", 30), true},
+		{strings.Repeat("Ж", 31), false},
+	}
+	for _, tt := range tests {
+		if got := IsValidName(tt.name); got != tt.want {
+			t.Errorf("IsValidName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	SetNamePolicy(personname.Policy{MinLength: 2, MaxLength: 5})
+	defer SetNamePolicy(defaultNamePolicy)
+	if IsValidName("J") || IsValidName("Johnny") || !IsValidName("John") {
+		t.Error("custom name policy not applied")
+	}
+}
+
+func TestNewUserNormalizesName(t *testing.T) {
+	user, err := NewUser("  Jose\u0301  ", 30, "jose@example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if user.Name != "Jos\u00e9" {
+		t.Errorf("Expected trimmed NFC name, got %q", user.Name)
+	}
+}
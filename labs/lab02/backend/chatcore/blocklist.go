@@ -0,0 +1,20 @@
+package chatcore
+
+// Blocker tells whether a user blocked another one, a *user.UserManager is one
+type Blocker interface {
+	IsBlocked(userID, senderID string) bool
+}
+
+// WithBlocker makes the broker consult blocker before delivering: direct
+// messages and events from a blocked sender are dropped silently, rooms and
+// broadcasts skip the users who blocked the sender, and so does Range
+func WithBlocker(blocker Blocker) Option {
+	return func(b *Broker) {
+		b.blocker = blocker
+	}
+}
+
+// blocks reports whether userID blocked senderID
+func (b *Broker) blocks(userID, senderID string) bool {
+	return b.blocker != nil && senderID != "" && b.blocker.IsBlocked(userID, senderID)
+}
@@ -0,0 +1,58 @@
+package chatcore
+
+import (
+	"testing"
+
+	"lab02/user"
+)
+
+var _ Blocker = (*user.UserManager)(nil)
+
+func TestBlockedSenders(t *testing.T) {
+	users := user.NewUserManager()
+	for _, id := range []string{"alice", "bob", "carol", "mallory"} {
+		users.AddUser(user.User{Name: id, Email: id + "@example.com", ID: id})
+	}
+	users.Block("alice", "mallory")
+	users.Block("carol", "mallory")
+	broker := startBroker(t, WithBlocker(users))
+
+	alice, bob := newTestUser("alice"), newTestUser("bob")
+	broker.RegisterUser(alice.ID, alice.Recv)
+	broker.RegisterUser(bob.ID, bob.Recv)
+
+	// dropped silently, the sender cannot tell
+	if err := broker.SendMessage(Message{Sender: "mallory", Recipient: "alice", Content: "psst"}); err != nil {
+		t.Errorf("expected no error for a blocked sender, got %v", err)
+	}
+	broker.SendMessage(Message{Kind: KindTyping, Sender: "mallory", Recipient: "alice"})
+	broker.SendMessage(Message{Sender: "mallory", Recipient: "carol", Content: "for later"})
+	broker.SendMessage(Message{Sender: "mallory", Broadcast: true, Content: "everyone"})
+	broker.JoinRoom("general", "alice")
+	broker.JoinRoom("general", "bob")
+	broker.JoinRoom("general", "mallory")
+	broker.SendMessage(Message{Sender: "mallory", Room: "general", Content: "room"})
+	broker.SendMessage(Message{Sender: "bob", Recipient: "alice", Content: "from bob"})
+
+	if m := receive(t, alice.Recv); m.Sender != "bob" || m.Content != "from bob" {
+		t.Errorf("expected only bob's message, got %+v", m)
+	}
+	for _, want := range []string{"everyone", "room"} {
+		if m := receive(t, bob.Recv); m.Content != want {
+			t.Errorf("bob: expected %q, got %q", want, m.Content)
+		}
+	}
+	if n := broker.Pending("carol"); n != 0 {
+		t.Errorf("expected nothing queued for carol, got %d", n)
+	}
+	if msgs, _ := broker.Range("alice", "room:general", 1, 0); len(msgs) != 0 {
+		t.Errorf("expected the history to hide mallory, got %+v", msgs)
+	}
+
+	// unblocking takes effect right away
+	users.Unblock("alice", "mallory")
+	broker.SendMessage(Message{Sender: "mallory", Recipient: "alice", Content: "hello again"})
+	if m := receive(t, alice.Recv); m.Content != "hello again" {
+		t.Errorf("expected the message after unblocking, got %+v", m)
+	}
+}
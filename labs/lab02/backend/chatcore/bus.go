@@ -0,0 +1,295 @@
+package chatcore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Bus carries messages between the nodes of a broker running on several
+// instances. Every node subscribes to the topic of each user registered on
+// it, so a direct message published to that topic reaches exactly the node
+// serving its recipient.
+type Bus interface {
+	// Publish delivers payload to the current subscribers of topic and
+	// returns how many there were
+	Publish(ctx context.Context, topic string, payload []byte) (int, error)
+	// Subscribe calls handler with every payload published to topic until
+	// unsubscribe is called. Handlers of one subscription are called in order
+	// and must not subscribe or unsubscribe themselves.
+	Subscribe(topic string, handler func(payload []byte)) (unsubscribe func())
+}
+
+// Reconnecter is implemented by a Bus that may lose messages while it
+// reconnects. The broker registers fn to announce its users again, so other
+// nodes send on the direct messages they kept for them meanwhile.
+type Reconnecter interface {
+	OnReconnect(fn func())
+}
+
+// Topics used on the bus
+const (
+	// busAll carries broadcasts, room messages and events every node applies
+	busAll = "chatcore.all"
+	// busUser prefixes the topic of direct messages to a user
+	busUser = "chatcore.user."
+)
+
+// Bus events
+const (
+	eventMessage = "message"
+	eventJoin    = "join"
+	eventLeave   = "leave"
+	// eventOnline announces a user registered on a node, other nodes drop their
+	// registration of that user and flush its offline messages
+	eventOnline = "online"
+	// eventPresence carries a heartbeat or an unregistration
+	eventPresence = "presence"
+)
+
+// envelope is the payload published on the bus
+type envelope struct {
+	Node    string   `json:"node"`
+	Event   string   `json:"event"`
+	Message *Message `json:"message,omitempty"`
+	Room    string   `json:"room,omitempty"`
+	User    string   `json:"user,omitempty"`
+	Online  bool     `json:"online,omitempty"`
+	At      int64    `json:"at,omitempty"` // presence time in Unix nanoseconds
+}
+
+// WithBus connects the broker to other nodes through bus, node names this
+// broker among them. Messages sent on any node are delivered exactly once to
+// each recipient as long as a user is registered on one node at a time,
+// registering on a node takes the user over from the others. Message IDs
+// start from a prefix derived from node, so node names must be unique.
+// Messages are not sequenced, Range returns ErrUnsequenced.
+func WithBus(bus Bus, node string) Option {
+	return func(b *Broker) {
+		b.bus = bus
+		b.node = node
+	}
+}
+
+// joinBus subscribes to the topic shared by all nodes
+func (b *Broker) joinBus() {
+	if b.bus == nil {
+		return
+	}
+	unsubscribe := b.bus.Subscribe(busAll, b.onBusAll)
+	b.busMutex.Lock()
+	b.busSubs[busAll] = unsubscribe
+	b.busMutex.Unlock()
+	if r, ok := b.bus.(Reconnecter); ok {
+		r.OnReconnect(b.reannounce)
+	}
+}
+
+// nodeIDBits is the number of low bits of a message ID counted by its node
+const nodeIDBits = 44
+
+// nodeIDs returns the message ID a node counts up from: the high bits hash
+// the node name, so the IDs of different nodes do not collide
+func nodeIDs(node string) uint64 {
+	sum := sha256.Sum256([]byte(node))
+	return binary.BigEndian.Uint64(sum[:]) >> nodeIDBits << nodeIDBits
+}
+
+// reannounce announces every user registered here again, messages other nodes
+// queued for them while the bus was down are then sent on
+func (b *Broker) reannounce() {
+	if b.accepting() != nil {
+		return
+	}
+	b.usersMutex.RLock()
+	users := make([]string, 0, len(b.users))
+	for id := range b.users {
+		users = append(users, id)
+	}
+	b.usersMutex.RUnlock()
+
+	now := b.now()
+	for _, id := range users {
+		b.announce(busAll, envelope{Event: eventOnline, User: id, At: now.UnixNano()})
+	}
+}
+
+// leaveBus drops every subscription of the broker
+func (b *Broker) leaveBus() {
+	if b.bus == nil {
+		return
+	}
+	b.busMutex.Lock()
+	subs := b.busSubs
+	b.busSubs = make(map[string]func())
+	b.busMutex.Unlock()
+
+	for _, unsubscribe := range subs {
+		unsubscribe()
+	}
+}
+
+// subscribeUser starts receiving the direct messages of a user registered here
+func (b *Broker) subscribeUser(userID string) {
+	b.busMutex.Lock()
+	defer b.busMutex.Unlock()
+
+	delete(b.moved, userID)
+	topic := busUser + userID
+	if _, ok := b.busSubs[topic]; !ok {
+		b.busSubs[topic] = b.bus.Subscribe(topic, b.onBusUser)
+	}
+}
+
+// unsubscribeUser stops receiving the direct messages of a user, unless the
+// user registered here again in the meantime
+func (b *Broker) unsubscribeUser(userID string) {
+	b.usersMutex.RLock()
+	_, registered := b.users[userID]
+	b.usersMutex.RUnlock()
+	if registered {
+		return
+	}
+
+	b.busMutex.Lock()
+	topic := busUser + userID
+	unsubscribe := b.busSubs[topic]
+	delete(b.busSubs, topic)
+	b.busMutex.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// dispatch sends a message to its recipients, through the bus if there is one
+func (b *Broker) dispatch(msg Message) error {
+	if b.bus == nil {
+		b.route(msg)
+		return nil
+	}
+	if msg.Room != "" || msg.Broadcast {
+		_, err := b.announce(busAll, envelope{Event: eventMessage, Message: &msg})
+		return err
+	}
+	n, err := b.announce(busUser+msg.Recipient, envelope{Event: eventMessage, Message: &msg})
+	if err != nil {
+		return err
+	}
+	if n == 0 && !msg.ephemeral() {
+		// no node serves the recipient, keep the message until one does
+		b.offline.push(msg, b.now())
+	}
+	return nil
+}
+
+// announce publishes an event of this node
+func (b *Broker) announce(topic string, env envelope) (int, error) {
+	env.Node = b.node
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+	return b.bus.Publish(b.ctx, topic, payload)
+}
+
+func (b *Broker) onBusAll(payload []byte) {
+	var env envelope
+	if json.Unmarshal(payload, &env) != nil {
+		return
+	}
+	switch env.Event {
+	case eventMessage:
+		if env.Message != nil {
+			b.accept(*env.Message)
+		}
+	case eventJoin:
+		if env.Node != b.node {
+			b.joinLocal(env.Room, env.User)
+		}
+	case eventLeave:
+		if env.Node != b.node {
+			b.leaveLocal(env.Room, env.User)
+		}
+	case eventOnline:
+		if env.Node != b.node {
+			b.presence.set(env.User, true, time.Unix(0, env.At))
+			b.usersMutex.RLock()
+			sub := b.users[env.User]
+			b.usersMutex.RUnlock()
+			// taking over unsubscribes, which handlers must not do
+			go b.takeOver(env.User, sub)
+		}
+	case eventPresence:
+		if env.Node != b.node {
+			b.presence.set(env.User, env.Online, time.Unix(0, env.At))
+		}
+	}
+}
+
+func (b *Broker) onBusUser(payload []byte) {
+	var env envelope
+	if json.Unmarshal(payload, &env) != nil || env.Message == nil {
+		return
+	}
+	b.accept(*env.Message)
+}
+
+// accept hands a message from the bus to the event loop, which delivers it to
+// the users registered here
+func (b *Broker) accept(msg Message) {
+	select {
+	case b.input <- msg:
+	case <-b.ctx.Done():
+	case <-b.stopping:
+	}
+}
+
+// takeOver runs when a user registered on another node: sub, the registration
+// of the user here at that time, is dropped and messages kept for the user are
+// sent on. Nothing happens if the user registered here again since.
+func (b *Broker) takeOver(userID string, sub *subscriber) {
+	b.usersMutex.RLock()
+	current := b.users[userID]
+	b.usersMutex.RUnlock()
+	if current != nil && current != sub {
+		return
+	}
+
+	b.busMutex.Lock()
+	b.moved[userID] = true
+	b.busMutex.Unlock()
+
+	if sub != nil {
+		// the user is online on the other node, its presence stays as announced
+		b.evict(sub)
+	}
+
+	for _, msg := range b.offline.take(userID, b.now()) {
+		b.dispatch(msg)
+	}
+}
+
+// busState holds the subscriptions of a broker on its bus
+type busState struct {
+	bus      Bus
+	node     string
+	busMutex sync.Mutex
+	busSubs  map[string]func() // topic -> unsubscribe
+	// moved holds the users taken over by another node, direct messages still
+	// reaching this node for them were delivered by that node as well
+	moved map[string]bool
+}
+
+// movedAway reports whether another node took userID over
+func (b *Broker) movedAway(userID string) bool {
+	if b.bus == nil {
+		return false
+	}
+	b.busMutex.Lock()
+	defer b.busMutex.Unlock()
+	return b.moved[userID]
+}
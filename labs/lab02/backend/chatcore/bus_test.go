@@ -0,0 +1,101 @@
+package chatcore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newNodes starts n brokers sharing bus
+func newNodes(t *testing.T, bus Bus, n int) []*Broker {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	nodes := make([]*Broker, n)
+	for i := range nodes {
+		nodes[i] = NewBroker(ctx, WithBus(bus, string(rune('a'+i))))
+		go nodes[i].Run()
+	}
+	return nodes
+}
+
+// expectExactly checks recv gets the given contents in order and nothing more
+func expectExactly(t *testing.T, name string, recv chan Message, contents ...string) {
+	t.Helper()
+	for _, want := range contents {
+		select {
+		case m := <-recv:
+			if m.Content != want {
+				t.Errorf("%s: expected %q, got %q", name, want, m.Content)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: did not receive %q", name, want)
+		}
+	}
+	select {
+	case m := <-recv:
+		t.Errorf("%s: unexpected extra message %+v", name, m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// testExactlyOnce runs the same scenario against any bus implementation
+func testExactlyOnce(t *testing.T, bus Bus) {
+	nodes := newNodes(t, bus, 2)
+	a, b, c := newTestUser("A"), newTestUser("B"), newTestUser("C")
+	nodes[0].RegisterUser(a.ID, a.Recv)
+	nodes[1].RegisterUser(b.ID, b.Recv)
+	nodes[1].RegisterUser(c.ID, c.Recv)
+
+	if err := nodes[0].SendMessage(Message{Sender: a.ID, Recipient: b.ID, Content: "direct"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectExactly(t, "B", b.Recv, "direct")
+
+	nodes[1].SendMessage(Message{Sender: b.ID, Content: "everyone", Broadcast: true})
+	expectExactly(t, "A", a.Recv, "everyone")
+	expectExactly(t, "B", b.Recv, "everyone")
+	expectExactly(t, "C", c.Recv, "everyone")
+
+	// membership is shared, so a room spans both nodes
+	nodes[0].JoinRoom("general", a.ID)
+	nodes[1].JoinRoom("general", c.ID)
+	waitFor(t, "the rooms to sync", func() bool {
+		return nodes[0].IsMember("general", c.ID) && nodes[1].IsMember("general", a.ID)
+	})
+	nodes[1].SendMessage(Message{Sender: c.ID, Room: "general", Content: "room"})
+	expectExactly(t, "A", a.Recv, "room")
+	expectExactly(t, "B", b.Recv)
+	expectExactly(t, "C", c.Recv, "room")
+}
+
+func TestMemoryBusExactlyOnce(t *testing.T) {
+	testExactlyOnce(t, NewMemoryBus())
+}
+
+func TestBusOfflineAndTakeOver(t *testing.T) {
+	nodes := newNodes(t, NewMemoryBus(), 2)
+
+	// D is on no node, node a keeps the message until D shows up on node b
+	nodes[0].SendMessage(Message{Sender: "A", Recipient: "D", Content: "later"})
+	if n := nodes[0].Pending("D"); n != 1 {
+		t.Fatalf("expected the message to wait on the sending node, got %d", n)
+	}
+	d := newTestUser("D")
+	nodes[1].RegisterUser(d.ID, d.Recv)
+	expectExactly(t, "D", d.Recv, "later")
+
+	// registering on node a takes D over from node b
+	moved := newTestUser("D")
+	nodes[0].RegisterUser(moved.ID, moved.Recv)
+	waitFor(t, "node b to drop D", func() bool {
+		_, ok := nodes[1].Stats(d.ID)
+		return !ok
+	})
+	if _, ok := <-d.Recv; ok {
+		t.Error("expected the channel of the old registration to be closed")
+	}
+	nodes[1].SendMessage(Message{Sender: "A", Recipient: "D", Content: "moved"})
+	expectExactly(t, "D", moved.Recv, "moved")
+}
@@ -2,61 +2,353 @@ package chatcore
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by Broker
+var (
+	ErrBrokerStopped = errors.New("broker stopped")
+	ErrInvalidRoom   = errors.New("invalid room name")
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrNotMember     = errors.New("sender is not a member of the room")
+	ErrNoRecipient   = errors.New("message has no recipient")
+	ErrShuttingDown  = errors.New("broker is shutting down")
+)
+
+// Kind tells chat messages apart from the events the broker relays
+type Kind string
+
+const (
+	// KindMessage is a chat message
+	KindMessage Kind = ""
+	// KindDelivered tells the sender that the message Ref reached its recipient
+	KindDelivered Kind = "delivered"
+	// KindRead tells the sender that the recipient read the message Ref
+	KindRead Kind = "read"
+	// KindTyping tells the recipient, or the room, that the sender is typing.
+	// It only reaches users registered at the time and is never queued.
+	KindTyping Kind = "typing"
 )
 
 // Message represents a chat message
 // Sender, Recipient, Content, Broadcast, Timestamp
-// TODO: Add more fields if needed
+// Room targets the members of a room, it takes precedence over Recipient and Broadcast
 
 type Message struct {
+	// ID is assigned by SendMessage
+	ID   uint64
+	Kind Kind
+	// Seq numbers the chat messages of a conversation from 1, see ConversationOf.
+	// Events and the messages of a broker with a bus have no sequence number.
+	Seq          uint64
+	Conversation string
+	// Ref is the ID of the message an event refers to
+	Ref       uint64
 	Sender    string
 	Recipient string
+	Room      string
 	Content   string
 	Broadcast bool
+	// Timestamp is set by SendMessage, in Unix milliseconds
 	Timestamp int64
+	// Tags are added by the filters of the broker
+	Tags []string
 }
 
 // Broker handles message routing between users
-// Contains context, input channel, user registry, mutex, done channel
+// Contains context, input channel, user registry, room membership, mutex, done channel
 
 type Broker struct {
 	ctx        context.Context
-	input      chan Message            // Incoming messages
-	users      map[string]chan Message // userID -> receiving channel
-	usersMutex sync.RWMutex            // Protects users map
-	done       chan struct{}           // For shutdown
-	// TODO: Add more fields if needed
+	input      chan Message                   // Incoming messages
+	users      map[string]*subscriber         // userID -> queue in front of the receiving channel
+	rooms      map[string]map[string]struct{} // room -> member userIDs
+	usersMutex sync.RWMutex                   // Protects users and rooms maps
+	done       chan struct{}                  // For shutdown
+	queue      QueueConfig                    // Queue of users registered with RegisterUser
+	metrics    metrics
+	nextID     atomic.Uint64
+	offline    offlineQueues
+	receipts   bool         // Send delivery receipts
+	outbox     receiptQueue // Delivery receipts waiting to be sent
+	routed     routedLog    // Recipients of the latest direct messages, for MarkRead
+	now        func() time.Time
+	presence   presences
+	seq        sequencer
+	filters    []Filter
+	blocker    Blocker
+	busState
+	shutdownState
+}
+
+// Option configures a Broker
+type Option func(*Broker)
+
+// WithQueue sets the queue of users registered with RegisterUser
+func WithQueue(cfg QueueConfig) Option {
+	return func(b *Broker) {
+		b.queue = cfg
+	}
 }
 
 // NewBroker creates a new message broker
-func NewBroker(ctx context.Context) *Broker {
-	// TODO: Initialize broker fields
-	return &Broker{
+func NewBroker(ctx context.Context, opts ...Option) *Broker {
+	b := &Broker{
 		ctx:   ctx,
 		input: make(chan Message, 100),
-		users: make(map[string]chan Message),
+		users: make(map[string]*subscriber),
+		rooms: make(map[string]map[string]struct{}),
 		done:  make(chan struct{}),
+		offline: offlineQueues{
+			cfg:    OfflineConfig{}.withDefaults(),
+			queues: make(map[string][]offlineMessage),
+		},
+		now: time.Now,
+	}
+	b.offline.metrics = &b.metrics
+	b.presence = presences{awayAfter: DefaultAwayAfter, users: make(map[string]presenceState)}
+	b.busSubs = make(map[string]func())
+	b.stopping = make(chan struct{})
+	b.seq = sequencer{size: DefaultHistorySize, conversations: make(map[string]*conversation)}
+	b.moved = make(map[string]bool)
+	b.outbox.ready = make(chan struct{}, 1)
+	for _, opt := range opts {
+		opt(b)
 	}
+	if b.bus != nil {
+		b.nextID.Store(nodeIDs(b.node))
+	}
+	b.joinBus()
+	return b
 }
 
-// Run starts the broker event loop (goroutine)
+// Run starts the broker event loop (goroutine), it returns once the context
+// of the broker is cancelled or Shutdown is called
 func (b *Broker) Run() {
-	// TODO: Implement event loop (fan-in/fan-out pattern)
+	defer close(b.done)
+	defer b.leaveBus()
+	if b.receipts {
+		go b.sendReceipts()
+	}
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case msg := <-b.input:
+			b.route(msg)
+		}
+	}
+}
+
+// route queues a message for each of its recipients, a full queue is handled
+// by the policy of its subscriber
+func (b *Broker) route(msg Message) {
+	b.routed.record(msg)
+	for _, sub := range b.recipients(msg) {
+		if !sub.enqueue(msg) {
+			b.disconnect(sub)
+		}
+	}
+}
+
+// recipients returns the subscribers a message is delivered to: the members
+// of its room, every user for a broadcast, or its recipient, leaving out the
+// users who blocked the sender. A direct message to a user who is not
+// registered is queued until they register.
+func (b *Broker) recipients(msg Message) []*subscriber {
+	b.usersMutex.RLock()
+	defer b.usersMutex.RUnlock()
+
+	var result []*subscriber
+	switch {
+	case msg.Room != "":
+		for member := range b.rooms[msg.Room] {
+			if sub, ok := b.users[member]; ok && !b.blocks(member, msg.Sender) {
+				result = append(result, sub)
+			}
+		}
+	case msg.Broadcast:
+		for id, sub := range b.users {
+			if !b.blocks(id, msg.Sender) {
+				result = append(result, sub)
+			}
+		}
+	default:
+		if b.blocks(msg.Recipient, msg.Sender) {
+			break
+		}
+		if sub, ok := b.users[msg.Recipient]; ok {
+			result = append(result, sub)
+		} else if !msg.ephemeral() && !b.movedAway(msg.Recipient) {
+			b.offline.push(msg, b.now())
+		}
+	}
+	return result
+}
+
+// disconnect unregisters a subscriber that fell too far behind and closes its channel
+func (b *Broker) disconnect(sub *subscriber) {
+	if b.evict(sub) {
+		b.setPresence(sub.id, false)
+	}
 }
 
-// SendMessage sends a message to the broker
+// evict closes a subscriber and removes it unless its user registered again,
+// it reports whether sub was still the registration of its user
+func (b *Broker) evict(sub *subscriber) bool {
+	b.usersMutex.Lock()
+	current := b.users[sub.id] == sub
+	if current {
+		delete(b.users, sub.id)
+	}
+	b.usersMutex.Unlock()
+
+	sub.halt(true)
+	b.metrics.disconnects.Add(1)
+	if b.bus != nil {
+		b.unsubscribeUser(sub.id)
+	}
+	return current
+}
+
+// SendMessage sends a message to the broker. A room message is only accepted
+// from a member of the room, and a chat message only if every filter of the
+// broker accepts it.
 func (b *Broker) SendMessage(msg Message) error {
-	// TODO: Send message to appropriate channel/queue
-	return nil
+	_, err := b.Send(msg)
+	return err
+}
+
+// Send is SendMessage returning the message as the broker numbered it. Chat
+// messages of a conversation reach each recipient in the order of their
+// sequence numbers. With a Bus each node numbers the messages sent through it.
+// A send waiting for the event loop only holds up its own conversation.
+func (b *Broker) Send(msg Message) (Message, error) {
+	b.sendMutex.RLock()
+	defer b.sendMutex.RUnlock()
+
+	if err := b.accepting(); err != nil {
+		return Message{}, err
+	}
+	if msg.Room != "" && !b.IsMember(msg.Room, msg.Sender) {
+		return Message{}, ErrNotMember
+	}
+	if msg.Room == "" && !msg.Broadcast && msg.Recipient == "" {
+		return Message{}, ErrNoRecipient
+	}
+	msg.Timestamp = b.now().UnixMilli()
+	if msg.Kind == KindMessage {
+		if err := b.filter(&msg); err != nil {
+			return Message{}, err
+		}
+	}
+	msg.ID = b.nextID.Add(1)
+	msg.Seq, msg.Conversation = 0, ""
+	if msg.Room == "" && !msg.Broadcast && b.blocks(msg.Recipient, msg.Sender) {
+		// the sender must not learn it is blocked
+		return msg, nil
+	}
+	if msg.Kind == KindMessage && b.bus == nil {
+		c := b.seq.number(&msg)
+		defer c.sending.Unlock()
+	}
+	if err := b.submit(msg); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
 }
 
-// RegisterUser adds a user to the broker
+// submit hands an accepted message to the event loop, or to the bus
+func (b *Broker) submit(msg Message) error {
+	if b.bus != nil {
+		return b.dispatch(msg)
+	}
+	select {
+	case b.input <- msg:
+		return nil
+	case <-b.ctx.Done():
+		return ErrBrokerStopped
+	}
+}
+
+// RegisterUser adds a user to the broker with the queue configured for the broker
 func (b *Broker) RegisterUser(userID string, recv chan Message) {
-	// TODO: Register user and their receiving channel
+	b.RegisterUserWithQueue(userID, recv, b.queue)
+}
+
+// RegisterUserWithQueue adds a user to the broker with its own queue. A user
+// registered again only receives on the new channel. Messages queued while
+// the user was offline are delivered first. After Shutdown recv is closed
+// right away.
+func (b *Broker) RegisterUserWithQueue(userID string, recv chan Message, cfg QueueConfig) {
+	sub := newSubscriber(userID, recv, cfg, &b.metrics)
+	sub.onDeliver = b.delivered
+
+	b.usersMutex.Lock()
+	if b.closed {
+		// shut down, the user gets no messages
+		b.usersMutex.Unlock()
+		close(recv)
+		return
+	}
+	sub.queue = b.offline.take(userID, b.now())
+	old := b.users[userID]
+	b.users[userID] = sub
+	b.usersMutex.Unlock()
+
+	go sub.run(b.ctx)
+
+	if old != nil {
+		old.halt(false)
+	}
+	now := b.now()
+	b.presence.set(userID, true, now)
+	if b.bus != nil {
+		b.subscribeUser(userID)
+		b.announce(busAll, envelope{Event: eventOnline, User: userID, At: now.UnixNano()})
+	}
 }
 
-// UnregisterUser removes a user from the broker
+// UnregisterUser removes a user from the broker, the user stays a member of
+// its rooms. Queued messages are discarded and the channel is left open.
 func (b *Broker) UnregisterUser(userID string) {
-	// TODO: Remove user from registry
+	b.usersMutex.Lock()
+	sub := b.users[userID]
+	delete(b.users, userID)
+	b.usersMutex.Unlock()
+
+	if sub != nil {
+		sub.halt(false)
+		b.setPresence(userID, false)
+	}
+	if b.bus != nil {
+		b.unsubscribeUser(userID)
+	}
+}
+
+// Stats returns the queue statistics of a registered user
+func (b *Broker) Stats(userID string) (SubscriberStats, bool) {
+	b.usersMutex.RLock()
+	sub, ok := b.users[userID]
+	b.usersMutex.RUnlock()
+
+	if !ok {
+		return SubscriberStats{}, false
+	}
+	return sub.stats(), true
+}
+
+// Metrics returns the delivery totals of the broker
+func (b *Broker) Metrics() Metrics {
+	return Metrics{
+		Delivered:   b.metrics.delivered.Load(),
+		Dropped:     b.metrics.dropped.Load(),
+		Disconnects: b.metrics.disconnects.Load(),
+		Expired:     b.metrics.expired.Load(),
+	}
 }
@@ -0,0 +1,157 @@
+package chatcore
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrRejected matches the errors of filters rejecting a message, see Reject
+var ErrRejected = errors.New("message rejected")
+
+// RejectionError is returned by SendMessage when a filter rejects a message
+type RejectionError struct {
+	Reason string
+}
+
+func (e *RejectionError) Error() string {
+	return "message rejected: " + e.Reason
+}
+
+// Is makes errors.Is(err, ErrRejected) hold for every rejection
+func (e *RejectionError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Reject returns the error a filter rejects a message with
+func Reject(reason string) error {
+	return &RejectionError{Reason: reason}
+}
+
+// Filter inspects a chat message before it is routed. It may redact the
+// content or add tags by changing msg, or return an error, usually from
+// Reject, which SendMessage returns without sending the message. Filters are
+// called concurrently.
+type Filter func(msg *Message) error
+
+// WithFilters appends filters to the chain run on every chat message, in order
+func WithFilters(filters ...Filter) Option {
+	return func(b *Broker) {
+		b.filters = append(b.filters, filters...)
+	}
+}
+
+// filter runs the chain on msg, stopping at the first error
+func (b *Broker) filter(msg *Message) error {
+	for _, f := range b.filters {
+		if err := f(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaxLength rejects messages longer than n characters
+func MaxLength(n int) Filter {
+	return func(msg *Message) error {
+		if utf8.RuneCountInString(msg.Content) > n {
+			return Reject("longer than " + strconv.Itoa(n) + " characters")
+		}
+		return nil
+	}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)\S+`)
+
+// BlockLinks rejects messages containing a link
+func BlockLinks() Filter {
+	return func(msg *Message) error {
+		if linkPattern.MatchString(msg.Content) {
+			return Reject("links are not allowed")
+		}
+		return nil
+	}
+}
+
+// TagRedacted is added to the tags of a message RedactWords changed
+const TagRedacted = "redacted"
+
+// RedactWords masks the given words with asterisks, whatever their case, and
+// tags the messages it changed with TagRedacted
+func RedactWords(words ...string) Filter {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return func(msg *Message) error {
+		if len(words) == 0 || !pattern.MatchString(msg.Content) {
+			return nil
+		}
+		msg.Content = pattern.ReplaceAllStringFunc(msg.Content, func(word string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(word))
+		})
+		msg.Tags = append(msg.Tags, TagRedacted)
+		return nil
+	}
+}
+
+// RateLimit rejects the messages of a sender who already sent n messages
+// within the period before, going by the message timestamps. The broker
+// stamps each message before filtering it, so senders cannot forge them.
+func RateLimit(n int, per time.Duration) Filter {
+	return newRateLimiter(n, per).filter
+}
+
+// rateLimiter keeps the times of the recent messages of each sender, senders
+// quiet for a whole period are forgotten
+type rateLimiter struct {
+	n         int
+	per       time.Duration
+	mutex     sync.Mutex
+	sent      map[string][]time.Time // sender -> times of its recent messages
+	lastSweep time.Time
+}
+
+func newRateLimiter(n int, per time.Duration) *rateLimiter {
+	return &rateLimiter{n: n, per: per, sent: make(map[string][]time.Time)}
+}
+
+func (l *rateLimiter) filter(msg *Message) error {
+	now := time.UnixMilli(msg.Timestamp)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sweepLocked(now)
+	recent := l.sent[msg.Sender]
+	expired := 0
+	for expired < len(recent) && now.Sub(recent[expired]) >= l.per {
+		expired++
+	}
+	recent = recent[expired:]
+	if len(recent) >= l.n {
+		l.sent[msg.Sender] = recent
+		return Reject("sending too fast")
+	}
+	l.sent[msg.Sender] = append(recent, now)
+	return nil
+}
+
+// sweepLocked drops the senders whose latest message left the window, at most
+// once per period so the cost is spread over the messages of that period
+func (l *rateLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < l.per {
+		return
+	}
+	l.lastSweep = now
+	for sender, recent := range l.sent {
+		if len(recent) == 0 || now.Sub(recent[len(recent)-1]) >= l.per {
+			delete(l.sent, sender)
+		}
+	}
+}
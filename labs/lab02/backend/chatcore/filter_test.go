@@ -0,0 +1,119 @@
+package chatcore
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		content string
+		want    string
+		tags    []string
+		reject  bool
+	}{
+		{"short enough", MaxLength(5), "héllo", "héllo", nil, false},
+		{"too long", MaxLength(5), "hello!", "", nil, true},
+		{"no link", BlockLinks(), "see you at 5.30", "see you at 5.30", nil, false},
+		{"http link", BlockLinks(), "look https://example.com", "", nil, true},
+		{"www link", BlockLinks(), "go to WWW.example.com", "", nil, true},
+		{"clean", RedactWords("darn", "heck"), "hello there", "hello there", nil, false},
+		{"redacted", RedactWords("darn", "heck"), "Darn it, what the heck", "**** it, what the ****", []string{TagRedacted}, false},
+		{"inside a word", RedactWords("heck"), "checked", "checked", nil, false},
+	}
+	for _, tt := range tests {
+		msg := Message{Sender: "A", Recipient: "B", Content: tt.content}
+		err := tt.filter(&msg)
+		if tt.reject {
+			if !errors.Is(err, ErrRejected) {
+				t.Errorf("%s: expected a rejection, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || msg.Content != tt.want || !slices.Equal(msg.Tags, tt.tags) {
+			t.Errorf("%s: expected %q %v, got %q %v, %v", tt.name, tt.want, tt.tags, msg.Content, msg.Tags, err)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limit := RateLimit(2, time.Second)
+	start := time.Now()
+	tests := []struct {
+		sender string
+		after  time.Duration
+		reject bool
+	}{
+		{"A", 0, false},
+		{"A", 100 * time.Millisecond, false},
+		{"A", 200 * time.Millisecond, true},
+		{"B", 200 * time.Millisecond, false},
+		{"A", time.Second, false}, // the first message left the window
+		{"A", 1050 * time.Millisecond, true},
+	}
+	for i, tt := range tests {
+		msg := Message{Sender: tt.sender, Timestamp: start.Add(tt.after).UnixMilli()}
+		if err := limit(&msg); (err != nil) != tt.reject {
+			t.Errorf("%d: expected rejection %v, got %v", i, tt.reject, err)
+		}
+	}
+}
+
+func TestRateLimitForgetsQuietSenders(t *testing.T) {
+	limiter := newRateLimiter(1, time.Second)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.filter(&Message{Sender: fmt.Sprint("user", i), Timestamp: start.UnixMilli()})
+	}
+	limiter.filter(&Message{Sender: "late", Timestamp: start.Add(2 * time.Second).UnixMilli()})
+	if n := len(limiter.sent); n != 1 {
+		t.Errorf("expected only the latest sender to be kept, got %d", n)
+	}
+}
+
+func TestRateLimitIgnoresForgedTimestamps(t *testing.T) {
+	broker := startBroker(t, WithFilters(RateLimit(2, time.Minute)))
+	clock := time.Now()
+	broker.now = func() time.Time { return clock }
+
+	forged := []int64{0, clock.Add(-time.Hour).UnixMilli(), clock.Add(time.Hour).UnixMilli()}
+	for i, timestamp := range forged {
+		msg, err := broker.Send(Message{Sender: "A", Recipient: "B", Content: "spam", Timestamp: timestamp})
+		if reject := i >= 2; errors.Is(err, ErrRejected) != reject {
+			t.Errorf("%d: expected rejection %v, got %v", i, reject, err)
+		}
+		if err == nil && msg.Timestamp != clock.UnixMilli() {
+			t.Errorf("%d: expected the broker time, got %d", i, msg.Timestamp)
+		}
+	}
+}
+
+func TestBrokerFilterChain(t *testing.T) {
+	broker := startBroker(t, WithFilters(MaxLength(20), RedactWords("darn")))
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+
+	err := broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "this is far too long to send"})
+	var rejection *RejectionError
+	if !errors.As(err, &rejection) || rejection.Reason != "longer than 20 characters" {
+		t.Fatalf("expected the rejection reason, got %v", err)
+	}
+	if last := broker.LastSeq("dm:A|B"); last != 0 {
+		t.Errorf("a rejected message must not be numbered, last seq %d", last)
+	}
+
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "darn"})
+	if m := receive(t, b.Recv); m.Content != "****" || !slices.Contains(m.Tags, TagRedacted) || m.Seq != 1 {
+		t.Errorf("expected the redacted message, got %+v", m)
+	}
+
+	// events are not filtered
+	if err := broker.SendMessage(Message{Kind: KindTyping, Sender: "A", Recipient: "B", Content: "this is far too long to send"}); err != nil {
+		t.Errorf("expected the typing event to pass, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package chatcore
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus is a Bus for brokers running in the same process, Publish calls
+// the handlers of the subscribers before it returns
+type MemoryBus struct {
+	mutex sync.RWMutex
+	subs  map[string]map[*memorySub]struct{} // topic -> subscriptions
+}
+
+type memorySub struct {
+	mutex   sync.Mutex // Keeps the calls of handler in order
+	handler func(payload []byte)
+}
+
+// NewMemoryBus creates an empty in-process bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[string]map[*memorySub]struct{})}
+}
+
+// Publish calls the handler of every subscriber of topic
+func (m *MemoryBus) Publish(ctx context.Context, topic string, payload []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mutex.RLock()
+	subs := make([]*memorySub, 0, len(m.subs[topic]))
+	for sub := range m.subs[topic] {
+		subs = append(subs, sub)
+	}
+	m.mutex.RUnlock()
+
+	for _, sub := range subs {
+		sub.mutex.Lock()
+		sub.handler(payload)
+		sub.mutex.Unlock()
+	}
+	return len(subs), nil
+}
+
+// Subscribe adds handler to the subscribers of topic
+func (m *MemoryBus) Subscribe(topic string, handler func(payload []byte)) func() {
+	sub := &memorySub{handler: handler}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.subs[topic] == nil {
+		m.subs[topic] = make(map[*memorySub]struct{})
+	}
+	m.subs[topic][sub] = struct{}{}
+
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.subs[topic], sub)
+		if len(m.subs[topic]) == 0 {
+			delete(m.subs, topic)
+		}
+	}
+}
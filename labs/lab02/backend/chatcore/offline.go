@@ -0,0 +1,311 @@
+package chatcore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotRecipient is returned by MarkRead for a message the reader did not receive
+var ErrNotRecipient = errors.New("message was not sent to the reader")
+
+// Defaults for OfflineConfig
+const (
+	DefaultOfflineSize  = 100
+	DefaultOfflineTTL   = 24 * time.Hour
+	DefaultOfflineTotal = 10000
+)
+
+// OfflineConfig bounds the direct messages kept for each user who is not registered
+type OfflineConfig struct {
+	// Size is the number of messages kept per user, DefaultOfflineSize if <= 0.
+	// Once full the oldest message is dropped.
+	Size int
+	// TTL is how long a message is kept, DefaultOfflineTTL if <= 0
+	TTL time.Duration
+	// Total is the number of messages kept for all users together,
+	// DefaultOfflineTotal if <= 0. Once full the expired messages of every
+	// user are dropped, then the oldest message of all.
+	Total int
+}
+
+func (c OfflineConfig) withDefaults() OfflineConfig {
+	if c.Size <= 0 {
+		c.Size = DefaultOfflineSize
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultOfflineTTL
+	}
+	if c.Total <= 0 {
+		c.Total = DefaultOfflineTotal
+	}
+	return c
+}
+
+// WithOfflineQueue sets the queue of direct messages for users who are not registered
+func WithOfflineQueue(cfg OfflineConfig) Option {
+	return func(b *Broker) {
+		b.offline.cfg = cfg.withDefaults()
+	}
+}
+
+type offlineMessage struct {
+	msg      Message
+	queuedAt time.Time
+}
+
+// offlineQueues holds the pending messages of each user who is not registered
+type offlineQueues struct {
+	cfg     OfflineConfig
+	metrics *metrics
+	mutex   sync.Mutex
+	queues  map[string][]offlineMessage
+	total   int // messages in all queues
+}
+
+// push queues msg for its recipient, dropping expired messages and the oldest
+// one if the queue or all queues together are full
+func (q *offlineQueues) push(msg Message, now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	queue := q.prune(q.queues[msg.Recipient], now)
+	if len(queue) >= q.cfg.Size {
+		queue = q.dropOldest(queue)
+	}
+	q.queues[msg.Recipient] = queue
+	if q.total >= q.cfg.Total {
+		q.makeRoom(now)
+	}
+	q.queues[msg.Recipient] = append(q.queues[msg.Recipient], offlineMessage{msg: msg, queuedAt: now})
+	q.total++
+}
+
+// makeRoom drops the expired messages of every user and, if all queues are
+// still full, the oldest message of all
+func (q *offlineQueues) makeRoom(now time.Time) {
+	oldest := ""
+	for userID, queue := range q.queues {
+		queue = q.prune(queue, now)
+		if len(queue) == 0 {
+			delete(q.queues, userID)
+			continue
+		}
+		q.queues[userID] = queue
+		if oldest == "" || queue[0].queuedAt.Before(q.queues[oldest][0].queuedAt) {
+			oldest = userID
+		}
+	}
+	if q.total < q.cfg.Total || oldest == "" {
+		return
+	}
+	if queue := q.dropOldest(q.queues[oldest]); len(queue) > 0 {
+		q.queues[oldest] = queue
+	} else {
+		delete(q.queues, oldest)
+	}
+}
+
+// dropOldest drops the first message of queue
+func (q *offlineQueues) dropOldest(queue []offlineMessage) []offlineMessage {
+	queue[0] = offlineMessage{}
+	q.total--
+	q.metrics.expired.Add(1)
+	return queue[1:]
+}
+
+// take removes and returns the messages still pending for userID
+func (q *offlineQueues) take(userID string, now time.Time) []Message {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	queue := q.prune(q.queues[userID], now)
+	delete(q.queues, userID)
+	q.total -= len(queue)
+	if len(queue) == 0 {
+		return nil
+	}
+	msgs := make([]Message, len(queue))
+	for i, pending := range queue {
+		msgs[i] = pending.msg
+	}
+	return msgs
+}
+
+// pending returns the number of messages queued for userID
+func (q *offlineQueues) pending(userID string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.queues[userID])
+}
+
+// clear discards every queued message and returns how many there were
+func (q *offlineQueues) clear() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	n := 0
+	for _, queue := range q.queues {
+		n += len(queue)
+	}
+	clear(q.queues)
+	q.total = 0
+	return n
+}
+
+// prune drops the messages that outlived the TTL from the front of queue
+func (q *offlineQueues) prune(queue []offlineMessage, now time.Time) []offlineMessage {
+	expired := 0
+	for expired < len(queue) && now.Sub(queue[expired].queuedAt) > q.cfg.TTL {
+		expired++
+	}
+	q.total -= expired
+	q.metrics.expired.Add(uint64(expired))
+	return queue[expired:]
+}
+
+// Pending returns the number of messages queued for a user who is not registered
+func (b *Broker) Pending(userID string) int {
+	return b.offline.pending(userID)
+}
+
+// WithReceipts makes the broker send a KindDelivered receipt to the sender of
+// a direct message once it was handed to its recipient
+func WithReceipts() Option {
+	return func(b *Broker) {
+		b.receipts = true
+	}
+}
+
+// receiptQueue holds the delivery receipts of the pumps until the receipt loop
+// sends them, so a pump never waits on routing
+type receiptQueue struct {
+	mutex   sync.Mutex
+	pending []Message
+	ready   chan struct{}
+}
+
+func (q *receiptQueue) push(msg Message) {
+	q.mutex.Lock()
+	q.pending = append(q.pending, msg)
+	q.mutex.Unlock()
+	signal(q.ready)
+}
+
+func (q *receiptQueue) take() []Message {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	pending := q.pending
+	q.pending = nil
+	return pending
+}
+
+// delivered queues a delivery receipt for a direct chat message to its sender,
+// it is called by the pump of the recipient
+func (b *Broker) delivered(to string, msg Message) {
+	if !b.receipts || msg.Kind != KindMessage || msg.Room != "" || msg.Broadcast || msg.Sender == "" || msg.Sender == to {
+		return
+	}
+	b.outbox.push(b.receipt(KindDelivered, to, msg.Sender, msg.ID))
+}
+
+// sendReceipts sends the queued delivery receipts until the broker stops, the
+// receipts still queued then are discarded
+func (b *Broker) sendReceipts() {
+	for {
+		select {
+		case <-b.outbox.ready:
+		case <-b.stopping:
+			return
+		case <-b.ctx.Done():
+			return
+		}
+		for _, receipt := range b.outbox.take() {
+			if err := b.sendReceipt(receipt); err == ErrShuttingDown || err == ErrBrokerStopped {
+				return
+			}
+		}
+	}
+}
+
+// sendReceipt submits a receipt like Send submits a message
+func (b *Broker) sendReceipt(receipt Message) error {
+	b.sendMutex.RLock()
+	defer b.sendMutex.RUnlock()
+
+	if err := b.accepting(); err != nil {
+		return err
+	}
+	return b.submit(receipt)
+}
+
+// MarkRead tells sender that reader read the message with the given ID, read
+// receipts are sent whether or not WithReceipts is set. The message must be
+// one of the latest direct messages from sender routed to reader, otherwise
+// MarkRead returns ErrNotRecipient.
+func (b *Broker) MarkRead(reader, sender string, messageID uint64) error {
+	if sender == "" {
+		return ErrNoRecipient
+	}
+	if from, ok := b.routed.sender(reader, messageID); !ok || from != sender {
+		return ErrNotRecipient
+	}
+	return b.sendReceipt(b.receipt(KindRead, reader, sender, messageID))
+}
+
+// routedWindow is the number of direct messages remembered for MarkRead
+const routedWindow = 10000
+
+type routedKey struct {
+	recipient string
+	id        uint64
+}
+
+// routedLog remembers the sender of the latest direct messages routed to each
+// recipient, dropping the oldest once routedWindow are kept
+type routedLog struct {
+	mutex   sync.Mutex
+	senders map[routedKey]string
+	order   []routedKey // ring of the keys in senders
+	next    int
+}
+
+func (l *routedLog) record(msg Message) {
+	if msg.Kind != KindMessage || msg.Room != "" || msg.Broadcast || msg.Recipient == "" {
+		return
+	}
+	key := routedKey{msg.Recipient, msg.ID}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.senders == nil {
+		l.senders = make(map[routedKey]string)
+		l.order = make([]routedKey, routedWindow)
+	}
+	if _, ok := l.senders[key]; ok {
+		return
+	}
+	delete(l.senders, l.order[l.next])
+	l.order[l.next] = key
+	l.next = (l.next + 1) % len(l.order)
+	l.senders[key] = msg.Sender
+}
+
+// sender returns the sender of message id routed to recipient
+func (l *routedLog) sender(recipient string, id uint64) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	sender, ok := l.senders[routedKey{recipient, id}]
+	return sender, ok
+}
+
+func (b *Broker) receipt(kind Kind, from, to string, ref uint64) Message {
+	return Message{
+		ID:        b.nextID.Add(1),
+		Kind:      kind,
+		Ref:       ref,
+		Sender:    from,
+		Recipient: to,
+		Timestamp: b.now().UnixMilli(),
+	}
+}
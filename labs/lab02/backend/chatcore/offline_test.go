@@ -0,0 +1,223 @@
+package chatcore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive returns the next message on recv or fails the test
+func receive(t *testing.T, recv chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-recv:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func TestOfflineQueue(t *testing.T) {
+	broker := startBroker(t, WithOfflineQueue(OfflineConfig{Size: 2}))
+	a := newTestUser("A")
+	broker.RegisterUser(a.ID, a.Recv)
+
+	for _, content := range []string{"1", "2", "3"} {
+		broker.SendMessage(Message{Sender: a.ID, Recipient: "B", Content: content})
+	}
+	waitFor(t, "the messages to be queued", func() bool {
+		return broker.Metrics().Expired == 1
+	})
+	if n := broker.Pending("B"); n != 2 {
+		t.Fatalf("expected 2 pending messages, got %d", n)
+	}
+
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+	broker.SendMessage(Message{Sender: a.ID, Recipient: b.ID, Content: "4"})
+	for _, want := range []string{"2", "3", "4"} {
+		if m := receive(t, b.Recv); m.Content != want {
+			t.Errorf("expected %q, got %q", want, m.Content)
+		}
+	}
+	if n := broker.Pending("B"); n != 0 {
+		t.Errorf("expected the queue to be flushed, %d messages left", n)
+	}
+}
+
+func TestOfflineQueueTTL(t *testing.T) {
+	broker := startBroker(t, WithOfflineQueue(OfflineConfig{TTL: time.Minute}))
+	clock := time.Now()
+	broker.now = func() time.Time { return clock }
+
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "stale"})
+	waitFor(t, "the message to be queued", func() bool { return broker.Pending("B") == 1 })
+	clock = clock.Add(2 * time.Minute)
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "fresh"})
+	waitFor(t, "the stale message to expire", func() bool { return broker.Metrics().Expired == 1 })
+
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+	if m := receive(t, b.Recv); m.Content != "fresh" {
+		t.Errorf("expected only the fresh message, got %q", m.Content)
+	}
+}
+
+func TestReceipts(t *testing.T) {
+	broker := startBroker(t, WithReceipts())
+	a, b := newTestUser("A"), newTestUser("B")
+	broker.RegisterUser(a.ID, a.Recv)
+	broker.RegisterUser(b.ID, b.Recv)
+
+	broker.SendMessage(Message{Sender: a.ID, Recipient: b.ID, Content: "hi"})
+	msg := receive(t, b.Recv)
+	if msg.ID == 0 {
+		t.Fatal("expected the broker to assign an ID")
+	}
+	receipt := receive(t, a.Recv)
+	if receipt.Kind != KindDelivered || receipt.Ref != msg.ID || receipt.Sender != b.ID {
+		t.Errorf("unexpected delivery receipt %+v", receipt)
+	}
+
+	if err := broker.MarkRead(b.ID, a.ID, msg.ID); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	receipt = receive(t, a.Recv)
+	if receipt.Kind != KindRead || receipt.Ref != msg.ID {
+		t.Errorf("unexpected read receipt %+v", receipt)
+	}
+
+	// broadcasts and receipts themselves are not acknowledged
+	broker.SendMessage(Message{Sender: a.ID, Content: "all", Broadcast: true})
+	receive(t, a.Recv)
+	receive(t, b.Recv)
+	select {
+	case m := <-a.Recv:
+		t.Errorf("unexpected message %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReceiptForOfflineSender(t *testing.T) {
+	broker := startBroker(t, WithReceipts())
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+
+	broker.SendMessage(Message{Sender: "A", Recipient: b.ID, Content: "hi"})
+	msg := receive(t, b.Recv)
+	waitFor(t, "the receipt to be queued", func() bool { return broker.Pending("A") == 1 })
+
+	a := newTestUser("A")
+	broker.RegisterUser(a.ID, a.Recv)
+	if receipt := receive(t, a.Recv); receipt.Kind != KindDelivered || receipt.Ref != msg.ID {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+	if err := broker.SendMessage(Message{Sender: "A", Content: "nowhere"}); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("expected ErrNoRecipient, got %v", err)
+	}
+}
+
+func TestReceiptsBetweenDisconnectedUsers(t *testing.T) {
+	broker := startBroker(t, WithReceipts(), WithQueue(QueueConfig{Size: 1, Policy: Disconnect}))
+	var readers sync.WaitGroup
+	for _, id := range []string{"A", "B"} {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			// register again each time the user is disconnected
+			for !broker.ShuttingDown() {
+				recv := make(chan Message, 1)
+				broker.RegisterUser(id, recv)
+				for range recv {
+				}
+			}
+		}()
+	}
+	waitFor(t, "both users to register", func() bool {
+		_, a := broker.Stats("A")
+		_, b := broker.Stats("B")
+		return a && b
+	})
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 2000; i++ {
+			broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "ping"})
+			broker.SendMessage(Message{Sender: "B", Recipient: "A", Content: "pong"})
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("senders stalled")
+	}
+	if m := broker.Metrics(); m.Disconnects == 0 {
+		t.Errorf("expected the users to be disconnected, got %+v", m)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		broker.Shutdown(ctx)
+		readers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a pump deadlocked on a receipt")
+	}
+}
+
+func TestOfflineTotal(t *testing.T) {
+	broker := startBroker(t, WithOfflineQueue(OfflineConfig{Size: 5, Total: 3}))
+
+	// users who never register share the total
+	for _, to := range []string{"B", "C", "D", "E"} {
+		broker.SendMessage(Message{Sender: "A", Recipient: to, Content: "to " + to})
+	}
+	waitFor(t, "the oldest message to be dropped", func() bool { return broker.Metrics().Expired == 1 })
+	if n := broker.Pending("B"); n != 0 {
+		t.Errorf("expected the message to B to be dropped, %d left", n)
+	}
+	for _, to := range []string{"C", "D", "E"} {
+		if n := broker.Pending(to); n != 1 {
+			t.Errorf("expected 1 message for %s, got %d", to, n)
+		}
+	}
+}
+
+func TestMarkReadNeedsRecipient(t *testing.T) {
+	broker := startBroker(t)
+	a, b := newTestUser("A"), newTestUser("B")
+	broker.RegisterUser(a.ID, a.Recv)
+	broker.RegisterUser(b.ID, b.Recv)
+
+	broker.SendMessage(Message{Sender: a.ID, Recipient: b.ID, Content: "hi"})
+	msg := receive(t, b.Recv)
+	tests := []struct {
+		reader, sender string
+		id             uint64
+	}{
+		{"C", a.ID, msg.ID},      // not the recipient
+		{a.ID, b.ID, msg.ID},     // the sender itself
+		{b.ID, "C", msg.ID},      // a different sender
+		{b.ID, a.ID, msg.ID + 1}, // no such message
+	}
+	for _, tt := range tests {
+		if err := broker.MarkRead(tt.reader, tt.sender, tt.id); err != ErrNotRecipient {
+			t.Errorf("MarkRead(%s, %s, %d): expected ErrNotRecipient, got %v", tt.reader, tt.sender, tt.id, err)
+		}
+	}
+	if err := broker.MarkRead(b.ID, a.ID, msg.ID); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	if receipt := receive(t, a.Recv); receipt.Kind != KindRead || receipt.Ref != msg.ID {
+		t.Errorf("unexpected read receipt %+v", receipt)
+	}
+}
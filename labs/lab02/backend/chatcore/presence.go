@@ -0,0 +1,128 @@
+package chatcore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotRegistered is returned for a user who is not registered on the broker
+var ErrNotRegistered = errors.New("user is not registered")
+
+// Status is the presence of a user
+type Status string
+
+const (
+	// Online users are registered and sent a heartbeat recently
+	Online Status = "online"
+	// Away users are registered but sent no heartbeat for the away timeout
+	Away Status = "away"
+	// Offline users are not registered
+	Offline Status = "offline"
+)
+
+// DefaultAwayAfter is how long a registered user stays online without a heartbeat
+const DefaultAwayAfter = 5 * time.Minute
+
+// WithAwayAfter sets how long a registered user stays online without a heartbeat
+func WithAwayAfter(d time.Duration) Option {
+	return func(b *Broker) {
+		if d > 0 {
+			b.presence.awayAfter = d
+		}
+	}
+}
+
+// Presence describes whether a user is around. LastSeen is the time of the
+// last registration, heartbeat or unregistration, zero for a user the broker
+// never saw.
+type Presence struct {
+	UserID   string
+	Status   Status
+	LastSeen time.Time
+}
+
+type presenceState struct {
+	online   bool
+	lastSeen time.Time
+}
+
+// presences holds the last known presence of each user, on every node when
+// the broker runs on a bus
+type presences struct {
+	awayAfter time.Duration
+	mutex     sync.Mutex
+	users     map[string]presenceState
+}
+
+// set records the presence of a user at the given time, unless a later one is known
+func (p *presences) set(userID string, online bool, at time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if cur, ok := p.users[userID]; ok && at.Before(cur.lastSeen) {
+		return
+	}
+	p.users[userID] = presenceState{online: online, lastSeen: at}
+}
+
+func (p *presences) get(userID string, now time.Time) Presence {
+	p.mutex.Lock()
+	state, ok := p.users[userID]
+	p.mutex.Unlock()
+
+	result := Presence{UserID: userID, Status: Offline, LastSeen: state.lastSeen}
+	switch {
+	case !ok || !state.online:
+	case now.Sub(state.lastSeen) > p.awayAfter:
+		result.Status = Away
+	default:
+		result.Status = Online
+	}
+	return result
+}
+
+// Heartbeat records that a registered user is still active, keeping it online
+func (b *Broker) Heartbeat(userID string) error {
+	if err := b.accepting(); err != nil {
+		return err
+	}
+	b.usersMutex.RLock()
+	_, ok := b.users[userID]
+	b.usersMutex.RUnlock()
+	if !ok {
+		return ErrNotRegistered
+	}
+	b.setPresence(userID, true)
+	return nil
+}
+
+// Presence returns the presence of a user
+func (b *Broker) Presence(userID string) Presence {
+	return b.presence.get(userID, b.now())
+}
+
+// Presences returns the presence of each of the given users, in order
+func (b *Broker) Presences(userIDs ...string) []Presence {
+	now := b.now()
+	result := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		result[i] = b.presence.get(userID, now)
+	}
+	return result
+}
+
+// setPresence records the presence of a user of this node and tells the other nodes
+func (b *Broker) setPresence(userID string, online bool) {
+	now := b.now()
+	b.presence.set(userID, online, now)
+	if b.bus != nil {
+		b.announce(busAll, envelope{Event: eventPresence, User: userID, Online: online, At: now.UnixNano()})
+	}
+}
+
+// ephemeral reports whether msg is only relayed to users registered when it
+// is routed, it is never queued for later
+func (m Message) ephemeral() bool {
+	return m.Kind == KindTyping
+}
@@ -0,0 +1,122 @@
+package chatcore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresence(t *testing.T) {
+	broker := startBroker(t, WithAwayAfter(time.Minute))
+	clock := time.Now()
+	broker.now = func() time.Time { return clock }
+
+	if p := broker.Presence("A"); p.Status != Offline || !p.LastSeen.IsZero() {
+		t.Errorf("expected an unknown user to be offline and never seen, got %+v", p)
+	}
+	if err := broker.Heartbeat("A"); err != ErrNotRegistered {
+		t.Errorf("expected ErrNotRegistered, got %v", err)
+	}
+
+	a := newTestUser("A")
+	broker.RegisterUser(a.ID, a.Recv)
+	if p := broker.Presence("A"); p.Status != Online || !p.LastSeen.Equal(clock) {
+		t.Errorf("expected A online since now, got %+v", p)
+	}
+
+	clock = clock.Add(50 * time.Second)
+	if err := broker.Heartbeat("A"); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	clock = clock.Add(50 * time.Second)
+	if p := broker.Presence("A"); p.Status != Online {
+		t.Errorf("expected the heartbeat to keep A online, got %s", p.Status)
+	}
+	clock = clock.Add(50 * time.Second)
+	if p := broker.Presence("A"); p.Status != Away {
+		t.Errorf("expected A away after a minute without heartbeat, got %s", p.Status)
+	}
+
+	broker.UnregisterUser("A")
+	tests := []struct {
+		user   string
+		status Status
+	}{
+		{"A", Offline},
+		{"B", Offline},
+	}
+	for i, p := range broker.Presences("A", "B") {
+		if p.UserID != tests[i].user || p.Status != tests[i].status {
+			t.Errorf("expected %s %s, got %+v", tests[i].user, tests[i].status, p)
+		}
+	}
+	if p := broker.Presence("A"); !p.LastSeen.Equal(clock) {
+		t.Errorf("expected A last seen when it unregistered, got %v", p.LastSeen)
+	}
+}
+
+func TestTypingIsNeverQueued(t *testing.T) {
+	broker := startBroker(t, WithQueue(QueueConfig{Size: 1, Policy: Disconnect}))
+	a := newTestUser("A")
+	broker.RegisterUser(a.ID, a.Recv)
+
+	broker.SendMessage(Message{Kind: KindTyping, Sender: "A", Recipient: "B"})
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "hi"})
+	waitFor(t, "the message to be queued", func() bool { return broker.Pending("B") == 1 })
+
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+	if m := receive(t, b.Recv); m.Kind != KindMessage || m.Content != "hi" {
+		t.Errorf("expected only the chat message to wait for B, got %+v", m)
+	}
+
+	broker.SendMessage(Message{Kind: KindTyping, Sender: "B", Recipient: "A"})
+	if m := receive(t, a.Recv); m.Kind != KindTyping || m.Sender != "B" {
+		t.Errorf("expected B typing, got %+v", m)
+	}
+
+	// a full queue drops typing events instead of disconnecting
+	broker.SendMessage(Message{Sender: "A", Recipient: "C", Content: "one"})
+	broker.SendMessage(Message{Sender: "A", Recipient: "C", Content: "two"})
+	waitFor(t, "the messages to be queued", func() bool { return broker.Pending("C") == 2 })
+	broker.RegisterUser("C", make(chan Message))
+	waitFor(t, "C's queue to fill", func() bool {
+		stats, _ := broker.Stats("C")
+		return stats.Queued == 1
+	})
+	broker.SendMessage(Message{Kind: KindTyping, Sender: "A", Recipient: "C"})
+	waitFor(t, "the typing event to be dropped", func() bool {
+		stats, _ := broker.Stats("C")
+		return stats.Dropped == 1
+	})
+	if _, ok := broker.Stats("C"); !ok || broker.Metrics().Disconnects != 0 {
+		t.Error("expected C to stay registered")
+	}
+}
+
+func TestPresenceAcrossNodes(t *testing.T) {
+	nodes := newNodes(t, NewMemoryBus(), 2)
+
+	a := newTestUser("A")
+	nodes[0].RegisterUser(a.ID, a.Recv)
+	if p := nodes[1].Presence("A"); p.Status != Online {
+		t.Errorf("expected node b to see A online, got %s", p.Status)
+	}
+
+	// moving to node b keeps A online everywhere
+	moved := newTestUser("A")
+	nodes[1].RegisterUser(moved.ID, moved.Recv)
+	waitFor(t, "node a to drop A", func() bool {
+		_, ok := nodes[0].Stats("A")
+		return !ok
+	})
+	for i, node := range nodes {
+		if p := node.Presence("A"); p.Status != Online {
+			t.Errorf("node %d: expected A online after moving, got %s", i, p.Status)
+		}
+	}
+
+	nodes[1].UnregisterUser("A")
+	if p := nodes[0].Presence("A"); p.Status != Offline {
+		t.Errorf("expected node a to see A offline, got %s", p.Status)
+	}
+}
@@ -0,0 +1,344 @@
+package chatcore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides what happens to a message for a subscriber whose queue is full
+type Policy int
+
+const (
+	// DropOldest discards the oldest queued message to make room
+	DropOldest Policy = iota
+	// DropNewest discards the incoming message
+	DropNewest
+	// Disconnect unregisters the subscriber and closes its channel
+	Disconnect
+	// Block keeps the incoming message waiting for room for up to
+	// QueueConfig.Timeout, then discards it. Messages wait per subscriber,
+	// routing to other users goes on.
+	Block
+)
+
+// String returns the name of the policy
+func (p Policy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	case Disconnect:
+		return "disconnect"
+	case Block:
+		return "block"
+	}
+	return "unknown"
+}
+
+// Defaults for QueueConfig
+const (
+	DefaultQueueSize    = 256
+	DefaultBlockTimeout = time.Second
+)
+
+// QueueConfig bounds the messages waiting for a subscriber that reads slower
+// than messages arrive
+type QueueConfig struct {
+	// Size is the number of queued messages, DefaultQueueSize if <= 0
+	Size   int
+	Policy Policy
+	// Timeout is how long a message waits for room under the Block policy,
+	// DefaultBlockTimeout if <= 0
+	Timeout time.Duration
+}
+
+func (c QueueConfig) withDefaults() QueueConfig {
+	if c.Size <= 0 {
+		c.Size = DefaultQueueSize
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultBlockTimeout
+	}
+	return c
+}
+
+// SubscriberStats describes the queue of a registered user
+type SubscriberStats struct {
+	Queued    int
+	Delivered uint64
+	Dropped   uint64
+}
+
+// Metrics are totals over every subscriber since the broker was created
+type Metrics struct {
+	Delivered   uint64
+	Dropped     uint64
+	Disconnects uint64
+	// Expired counts offline messages dropped because they outlived the TTL
+	// or did not fit in the offline queue
+	Expired uint64
+}
+
+type metrics struct {
+	delivered   atomic.Uint64
+	dropped     atomic.Uint64
+	disconnects atomic.Uint64
+	expired     atomic.Uint64
+}
+
+// subscriber queues the messages of one registered user, its pump goroutine
+// moves them to the user's channel so a slow reader only holds up itself
+type subscriber struct {
+	id      string
+	out     chan Message
+	cfg     QueueConfig
+	metrics *metrics
+	// onDeliver is called after a message was handed to the user
+	onDeliver func(to string, msg Message)
+
+	mutex    sync.Mutex
+	queue    []Message
+	overflow []waiting // Block: messages waiting for room in the queue
+	busy     bool      // the pump holds a message it has not handed over yet
+
+	ready     chan struct{} // signalled when a message is queued
+	space     chan struct{} // signalled when the pump takes a message
+	stop      chan struct{}
+	stopOnce  sync.Once
+	exited    chan struct{}
+	closeOnce sync.Once
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// waiting is a message of the overflow and when it is discarded
+type waiting struct {
+	msg      Message
+	deadline time.Time
+}
+
+func newSubscriber(id string, out chan Message, cfg QueueConfig, m *metrics) *subscriber {
+	return &subscriber{
+		id:      id,
+		out:     out,
+		cfg:     cfg.withDefaults(),
+		metrics: m,
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+}
+
+// run is the pump, it delivers queued messages until the subscriber is halted
+// or ctx is cancelled
+func (s *subscriber) run(ctx context.Context) {
+	defer close(s.exited)
+	for {
+		msg, ok := s.pop()
+		if !ok {
+			select {
+			case <-s.ready:
+				continue
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		if !s.deliver(ctx, msg) {
+			return
+		}
+	}
+}
+
+// deliver hands msg to the user, discarding the overflow messages that wait
+// past their deadline meanwhile. It returns false if the pump has to exit.
+func (s *subscriber) deliver(ctx context.Context, msg Message) bool {
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		var expire <-chan time.Time
+		if d, ok := s.nextDeadline(); ok {
+			timer.Reset(d)
+			expire = timer.C
+		}
+		select {
+		case s.out <- msg:
+			s.delivered.Add(1)
+			s.metrics.delivered.Add(1)
+			if s.onDeliver != nil {
+				s.onDeliver(s.id, msg)
+			}
+			s.mutex.Lock()
+			s.busy = false
+			s.mutex.Unlock()
+			signal(s.space)
+			return true
+		case <-expire:
+			s.mutex.Lock()
+			s.expireLocked(time.Now())
+			s.mutex.Unlock()
+		case <-s.ready:
+			// the overflow may have a new deadline
+		case <-s.stop:
+			s.drop(1)
+			return false
+		case <-ctx.Done():
+			s.drop(1)
+			return false
+		}
+	}
+}
+
+func (s *subscriber) pop() (Message, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.queue) == 0 {
+		return Message{}, false
+	}
+	msg := s.queue[0]
+	s.queue[0] = Message{}
+	s.queue = s.queue[1:]
+	s.busy = true
+
+	s.expireLocked(time.Now())
+	if len(s.overflow) > 0 {
+		s.queue = append(s.queue, s.overflow[0].msg)
+		s.overflow[0] = waiting{}
+		s.overflow = s.overflow[1:]
+	}
+	signal(s.space)
+	return msg, true
+}
+
+// expireLocked discards the overflow messages whose deadline passed, they are
+// the oldest ones
+func (s *subscriber) expireLocked(now time.Time) {
+	n := 0
+	for n < len(s.overflow) && !s.overflow[n].deadline.After(now) {
+		s.overflow[n] = waiting{}
+		n++
+	}
+	s.overflow = s.overflow[n:]
+	s.drop(n)
+}
+
+// nextDeadline returns the time until the oldest overflow message expires
+func (s *subscriber) nextDeadline() (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.overflow) == 0 {
+		return 0, false
+	}
+	return time.Until(s.overflow[0].deadline), true
+}
+
+// enqueue queues msg according to the policy without waiting, it returns false
+// when the subscriber has to be disconnected
+func (s *subscriber) enqueue(msg Message) bool {
+	s.mutex.Lock()
+	if len(s.queue) < s.cfg.Size && len(s.overflow) == 0 {
+		s.queue = append(s.queue, msg)
+		s.mutex.Unlock()
+		signal(s.ready)
+		return true
+	}
+
+	switch {
+	case msg.ephemeral():
+		// not worth making room for, nor waiting or disconnecting over
+		s.mutex.Unlock()
+		s.drop(1)
+		return true
+	case s.cfg.Policy == DropOldest:
+		s.queue[0] = Message{}
+		s.queue = append(s.queue[1:], msg)
+		s.mutex.Unlock()
+		s.drop(1)
+		return true
+	case s.cfg.Policy == Disconnect:
+		s.mutex.Unlock()
+		s.drop(1)
+		return false
+	case s.cfg.Policy == Block:
+		now := time.Now()
+		s.expireLocked(now)
+		s.overflow = append(s.overflow, waiting{msg: msg, deadline: now.Add(s.cfg.Timeout)})
+		s.mutex.Unlock()
+		signal(s.ready)
+		return true
+	default:
+		s.mutex.Unlock()
+		s.drop(1)
+		return true
+	}
+}
+
+func (s *subscriber) drop(n int) {
+	s.dropped.Add(uint64(n))
+	s.metrics.dropped.Add(uint64(n))
+}
+
+// drain waits until the pump handed every queued message to the user, it
+// returns false if ctx is done first
+func (s *subscriber) drain(ctx context.Context) bool {
+	for {
+		s.mutex.Lock()
+		idle := len(s.queue) == 0 && len(s.overflow) == 0 && !s.busy
+		s.mutex.Unlock()
+		if idle {
+			return true
+		}
+		select {
+		case <-s.space:
+		case <-s.exited:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// halt stops the pump and discards the queued messages. With closeOut the
+// channel of the user is closed once the pump no longer sends on it.
+func (s *subscriber) halt(closeOut bool) {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.exited
+
+		s.mutex.Lock()
+		s.drop(len(s.queue) + len(s.overflow))
+		s.queue = nil
+		s.overflow = nil
+		s.mutex.Unlock()
+	})
+	if closeOut {
+		s.closeOnce.Do(func() { close(s.out) })
+	}
+}
+
+func (s *subscriber) stats() SubscriberStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SubscriberStats{
+		Queued:    len(s.queue) + len(s.overflow),
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+// signal wakes up the receiver of ch without blocking
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
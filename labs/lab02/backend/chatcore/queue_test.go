@@ -0,0 +1,185 @@
+package chatcore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// startBroker runs a broker until the test ends
+func startBroker(t *testing.T, opts ...Option) *Broker {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	broker := NewBroker(ctx, opts...)
+	go broker.Run()
+	return broker
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSlowConsumerDoesNotDelayOthers(t *testing.T) {
+	for _, policy := range []Policy{DropOldest, DropNewest, Disconnect} {
+		t.Run(policy.String(), func(t *testing.T) {
+			broker := startBroker(t, WithQueue(QueueConfig{Size: 2, Policy: policy}))
+			slow := make(chan Message) // never read
+			fast := make(chan Message, 100)
+			broker.RegisterUser("slow", slow)
+			broker.RegisterUserWithQueue("fast", fast, QueueConfig{Size: 100})
+
+			const n = 50
+			start := time.Now()
+			for i := 0; i < n; i++ {
+				broker.SendMessage(Message{Sender: "fast", Content: "msg", Broadcast: true})
+			}
+			for i := 0; i < n; i++ {
+				select {
+				case <-fast:
+				case <-time.After(time.Second):
+					t.Fatalf("fast user only received %d of %d messages", i, n)
+				}
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("fast user was delayed by %v", elapsed)
+			}
+			if m := broker.Metrics(); m.Dropped == 0 {
+				t.Errorf("expected dropped messages for the slow user, got %+v", m)
+			}
+		})
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   []string
+	}{
+		{DropOldest, []string{"4", "5"}},
+		{DropNewest, []string{"2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			broker := startBroker(t)
+			recv := make(chan Message, 1)
+			broker.RegisterUserWithQueue("A", recv, QueueConfig{Size: 2, Policy: tt.policy})
+
+			// message 0 fills the channel and the pump holds message 1 until A
+			// reads, so 2 to 5 compete for the two places in the queue
+			broker.SendMessage(Message{Recipient: "A", Content: "0"})
+			broker.SendMessage(Message{Recipient: "A", Content: "1"})
+			waitFor(t, "the channel to fill", func() bool {
+				stats, _ := broker.Stats("A")
+				return stats.Delivered == 1 && stats.Queued == 0
+			})
+			for _, content := range []string{"2", "3", "4", "5"} {
+				broker.SendMessage(Message{Recipient: "A", Content: content})
+			}
+			waitFor(t, "the messages to be routed", func() bool {
+				stats, _ := broker.Stats("A")
+				return stats.Dropped == 2
+			})
+
+			for _, want := range append([]string{"0", "1"}, tt.want...) {
+				if m := <-recv; m.Content != want {
+					t.Errorf("expected %q, got %q", want, m.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestDisconnectPolicy(t *testing.T) {
+	broker := startBroker(t)
+	recv := make(chan Message)
+	broker.RegisterUserWithQueue("A", recv, QueueConfig{Size: 1, Policy: Disconnect})
+
+	for i := 0; i < 3; i++ {
+		broker.SendMessage(Message{Recipient: "A", Content: "msg"})
+	}
+	waitFor(t, "the disconnect", func() bool {
+		_, ok := broker.Stats("A")
+		return !ok
+	})
+
+	select {
+	case _, ok := <-recv:
+		if ok {
+			t.Error("expected the channel of a disconnected user to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("the channel of a disconnected user was not closed")
+	}
+	if m := broker.Metrics(); m.Disconnects != 1 {
+		t.Errorf("expected 1 disconnect, got %+v", m)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	broker := startBroker(t)
+	recv := make(chan Message)
+	broker.RegisterUserWithQueue("A", recv, QueueConfig{Size: 1, Policy: Block, Timeout: 200 * time.Millisecond})
+
+	// a reader keeping up within the timeout loses nothing
+	go func() {
+		for i := 0; i < 3; i++ {
+			broker.SendMessage(Message{Recipient: "A", Content: "msg"})
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		<-recv
+	}
+	waitFor(t, "the deliveries to be counted", func() bool {
+		stats, _ := broker.Stats("A")
+		return stats.Delivered == 3
+	})
+	if stats, _ := broker.Stats("A"); stats.Dropped != 0 {
+		t.Errorf("expected no drops, got %+v", stats)
+	}
+
+	// without a reader the message waits out the timeout and is dropped
+	for i := 0; i < 3; i++ {
+		broker.SendMessage(Message{Recipient: "A", Content: "msg"})
+	}
+	waitFor(t, "the blocked message to be dropped", func() bool {
+		stats, _ := broker.Stats("A")
+		return stats.Dropped == 1
+	})
+}
+
+func TestBlockPolicyDoesNotDelayOthers(t *testing.T) {
+	broker := startBroker(t, WithQueue(QueueConfig{Size: 2, Policy: Block, Timeout: 300 * time.Millisecond}))
+	blocked := make(chan Message) // never read
+	fast := make(chan Message, 100)
+	broker.RegisterUser("blocked", blocked)
+	broker.RegisterUserWithQueue("fast", fast, QueueConfig{Size: 100})
+
+	const n = 20
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		broker.SendMessage(Message{Sender: "fast", Content: "msg", Broadcast: true})
+	}
+	for i := 0; i < n; i++ {
+		receive(t, fast)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("fast user was delayed by %v", elapsed)
+	}
+
+	// the pump holds one message and two are queued, the others wait out the
+	// timeout
+	waitFor(t, "the waiting messages to be dropped", func() bool {
+		stats, _ := broker.Stats("blocked")
+		return stats.Queued == 2 && stats.Dropped == n-3
+	})
+}
@@ -0,0 +1,89 @@
+package chatcore
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// JoinRoom adds a user to a room, creating the room if needed
+func (b *Broker) JoinRoom(room, userID string) error {
+	if strings.TrimSpace(room) == "" {
+		return ErrInvalidRoom
+	}
+	b.joinLocal(room, userID)
+	if b.bus != nil {
+		_, err := b.announce(busAll, envelope{Event: eventJoin, Room: room, User: userID})
+		return err
+	}
+	return nil
+}
+
+// LeaveRoom removes a user from a room, the room is removed with its last member
+func (b *Broker) LeaveRoom(room, userID string) error {
+	if err := b.leaveLocal(room, userID); err != nil {
+		return err
+	}
+	if b.bus != nil {
+		_, err := b.announce(busAll, envelope{Event: eventLeave, Room: room, User: userID})
+		return err
+	}
+	return nil
+}
+
+func (b *Broker) joinLocal(room, userID string) {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+
+	members, ok := b.rooms[room]
+	if !ok {
+		members = make(map[string]struct{})
+		b.rooms[room] = members
+	}
+	members[userID] = struct{}{}
+}
+
+func (b *Broker) leaveLocal(room, userID string) error {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+
+	members, ok := b.rooms[room]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if _, ok := members[userID]; !ok {
+		return ErrNotMember
+	}
+	delete(members, userID)
+	if len(members) == 0 {
+		delete(b.rooms, room)
+	}
+	return nil
+}
+
+// Rooms returns the names of all rooms, sorted
+func (b *Broker) Rooms() []string {
+	b.usersMutex.RLock()
+	defer b.usersMutex.RUnlock()
+	return slices.Sorted(maps.Keys(b.rooms))
+}
+
+// Members returns the members of a room, sorted
+func (b *Broker) Members(room string) ([]string, error) {
+	b.usersMutex.RLock()
+	defer b.usersMutex.RUnlock()
+
+	members, ok := b.rooms[room]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return slices.Sorted(maps.Keys(members)), nil
+}
+
+// IsMember reports whether a user is a member of a room
+func (b *Broker) IsMember(room, userID string) bool {
+	b.usersMutex.RLock()
+	defer b.usersMutex.RUnlock()
+	_, ok := b.rooms[room][userID]
+	return ok
+}
@@ -0,0 +1,77 @@
+package chatcore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRoomMembership(t *testing.T) {
+	broker := NewBroker(context.Background())
+
+	broker.JoinRoom("general", "A")
+	broker.JoinRoom("general", "B")
+	broker.JoinRoom("random", "A")
+	if err := broker.JoinRoom(" ", "A"); !errors.Is(err, ErrInvalidRoom) {
+		t.Errorf("expected ErrInvalidRoom, got %v", err)
+	}
+
+	if got := broker.Rooms(); !slices.Equal(got, []string{"general", "random"}) {
+		t.Errorf("unexpected rooms %v", got)
+	}
+	if got, _ := broker.Members("general"); !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("unexpected members %v", got)
+	}
+
+	if err := broker.LeaveRoom("random", "A"); err != nil {
+		t.Fatalf("LeaveRoom failed: %v", err)
+	}
+	if _, err := broker.Members("random"); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("empty rooms should be removed, got %v", err)
+	}
+	if err := broker.LeaveRoom("general", "C"); !errors.Is(err, ErrNotMember) {
+		t.Errorf("expected ErrNotMember, got %v", err)
+	}
+	if err := broker.LeaveRoom("random", "A"); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("expected ErrRoomNotFound, got %v", err)
+	}
+}
+
+func TestRoomMessage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := NewBroker(ctx)
+	go broker.Run()
+
+	a, b, c := newTestUser("A"), newTestUser("B"), newTestUser("C")
+	for _, u := range []*testUser{a, b, c} {
+		broker.RegisterUser(u.ID, u.Recv)
+	}
+	broker.JoinRoom("general", a.ID)
+	broker.JoinRoom("general", b.ID)
+
+	if err := broker.SendMessage(Message{Sender: c.ID, Room: "general", Content: "let me in"}); !errors.Is(err, ErrNotMember) {
+		t.Errorf("expected ErrNotMember, got %v", err)
+	}
+	if err := broker.SendMessage(Message{Sender: a.ID, Room: "general", Content: "hi room"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	for _, u := range []*testUser{a, b} {
+		select {
+		case m := <-u.Recv:
+			if m.Content != "hi room" || m.Room != "general" {
+				t.Errorf("%s got wrong message: %+v", u.ID, m)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Errorf("%s did not receive the room message", u.ID)
+		}
+	}
+	select {
+	case m := <-c.Recv:
+		t.Errorf("C is not a member but received %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,193 @@
+package chatcore
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Errors returned by Range
+var (
+	// ErrHistoryGone is returned with the messages still kept when the start of
+	// the range was already dropped from the history
+	ErrHistoryGone = errors.New("history no longer available")
+	// ErrUnsequenced is returned by a broker with a bus, which does not number
+	// messages since each node only sees its own
+	ErrUnsequenced = errors.New("messages are not sequenced across nodes")
+)
+
+// DefaultHistorySize is the number of messages kept per conversation for Range
+const DefaultHistorySize = 1000
+
+// WithHistory sets the number of messages kept per conversation for Range
+func WithHistory(size int) Option {
+	return func(b *Broker) {
+		if size > 0 {
+			b.seq.size = size
+		}
+	}
+}
+
+// ConversationOf returns the conversation a message belongs to: its room, the
+// broadcasts, or the pair of users of a direct message
+func ConversationOf(msg Message) string {
+	switch {
+	case msg.Room != "":
+		return "room:" + msg.Room
+	case msg.Broadcast:
+		return "broadcast"
+	}
+	a, b := msg.Sender, msg.Recipient
+	if b < a {
+		a, b = b, a
+	}
+	return "dm:" + a + "|" + b
+}
+
+type conversation struct {
+	// sending is held from numbering a message until it is handed on, so the
+	// messages of the conversation are routed in the order of their numbers
+	// while a stalled send only holds up its own conversation
+	sending sync.Mutex
+	last    uint64
+	history []Message // the latest messages, by sequence number
+}
+
+// sequencer numbers the chat messages of each conversation, its mutex guards
+// the numbers and histories
+type sequencer struct {
+	mutex         sync.Mutex
+	size          int
+	conversations map[string]*conversation
+}
+
+// number assigns the next sequence number of its conversation to msg and keeps
+// it in the history. It returns the conversation with its sending mutex held,
+// the caller unlocks it once msg is handed on.
+func (s *sequencer) number(msg *Message) *conversation {
+	msg.Conversation = ConversationOf(*msg)
+	s.mutex.Lock()
+	c, ok := s.conversations[msg.Conversation]
+	if !ok {
+		c = &conversation{}
+		s.conversations[msg.Conversation] = c
+	}
+	s.mutex.Unlock()
+
+	c.sending.Lock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c.last++
+	msg.Seq = c.last
+
+	if len(c.history) >= s.size {
+		c.history[0] = Message{}
+		c.history = c.history[1:]
+	}
+	c.history = append(c.history, *msg)
+	return c
+}
+
+// LastSeq returns the sequence number of the latest message of a conversation
+func (b *Broker) LastSeq(conversation string) uint64 {
+	b.seq.mutex.Lock()
+	defer b.seq.mutex.Unlock()
+
+	if c, ok := b.seq.conversations[conversation]; ok {
+		return c.last
+	}
+	return 0
+}
+
+// Range returns the kept messages of a conversation numbered from to to,
+// inclusive, from the first if from is 0 and up to the latest if to is 0. A user reads the direct
+// conversations it takes part in, the rooms it is a member of and the
+// broadcasts, other conversations return ErrNotMember. Messages from users
+// it blocked are left out.
+func (b *Broker) Range(userID, conversation string, from, to uint64) ([]Message, error) {
+	if b.bus != nil {
+		return nil, ErrUnsequenced
+	}
+	from = max(from, 1)
+
+	b.seq.mutex.Lock()
+	var history []Message
+	if c, ok := b.seq.conversations[conversation]; ok {
+		history = slices.Clone(c.history)
+	}
+	b.seq.mutex.Unlock()
+	if len(history) == 0 {
+		return nil, nil
+	}
+
+	if first := history[0]; !b.mayRead(userID, first) {
+		return nil, ErrNotMember
+	}
+	var result []Message
+	for _, msg := range history {
+		if msg.Seq >= from && (to == 0 || msg.Seq <= to) && !b.blocks(userID, msg.Sender) {
+			result = append(result, msg)
+		}
+	}
+	if from < history[0].Seq {
+		return result, ErrHistoryGone
+	}
+	return result, nil
+}
+
+// mayRead reports whether userID may read the conversation of msg
+func (b *Broker) mayRead(userID string, msg Message) bool {
+	switch {
+	case msg.Room != "":
+		return b.IsMember(msg.Room, userID)
+	case msg.Broadcast:
+		return true
+	}
+	return msg.Sender == userID || msg.Recipient == userID
+}
+
+// SeqRange is an inclusive range of sequence numbers
+type SeqRange struct {
+	From, To uint64
+}
+
+// GapDetector follows the sequence numbers a client received in each
+// conversation and reports the ones it missed, to be fetched with Range.
+// Direct messages are not echoed to their sender, so a client observes the
+// messages returned by Send as well.
+type GapDetector struct {
+	last map[string]uint64
+}
+
+// NewGapDetector creates a detector that has seen no message yet
+func NewGapDetector() *GapDetector {
+	return &GapDetector{last: make(map[string]uint64)}
+}
+
+// Resume sets the last sequence number a client saw in a conversation, when it
+// reconnects with the state of a previous session
+func (g *GapDetector) Resume(conversation string, last uint64) {
+	g.last[conversation] = last
+}
+
+// Observe records msg and returns the range missed right before it. Events
+// carry no sequence number and are ignored, as are duplicates.
+func (g *GapDetector) Observe(msg Message) (SeqRange, bool) {
+	if msg.Seq == 0 {
+		return SeqRange{}, false
+	}
+	last := g.last[msg.Conversation]
+	if msg.Seq <= last {
+		return SeqRange{}, false
+	}
+	g.last[msg.Conversation] = msg.Seq
+	if msg.Seq == last+1 {
+		return SeqRange{}, false
+	}
+	return SeqRange{From: last + 1, To: msg.Seq - 1}, true
+}
+
+// Last returns the last sequence number observed in a conversation
+func (g *GapDetector) Last(conversation string) uint64 {
+	return g.last[conversation]
+}
@@ -0,0 +1,191 @@
+package chatcore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceOrderUnderLoad(t *testing.T) {
+	const senders, each = 4, 100
+	broker := startBroker(t, WithQueue(QueueConfig{Size: senders * each}))
+	users := make([]*testUser, senders)
+	for i := range users {
+		users[i] = &testUser{ID: string(rune('A' + i)), Recv: make(chan Message, senders*each)}
+		broker.RegisterUser(users[i].ID, users[i].Recv)
+		broker.JoinRoom("general", users[i].ID)
+	}
+
+	var wg sync.WaitGroup
+	for _, u := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				if err := broker.SendMessage(Message{Sender: u.ID, Room: "general", Content: "hi"}); err != nil {
+					t.Errorf("send failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, u := range users {
+		for want := uint64(1); want <= senders*each; want++ {
+			m := receive(t, u.Recv)
+			if m.Seq != want || m.Conversation != "room:general" {
+				t.Fatalf("%s: expected seq %d in room:general, got %d in %s", u.ID, want, m.Seq, m.Conversation)
+			}
+		}
+	}
+	if last := broker.LastSeq("room:general"); last != senders*each {
+		t.Errorf("expected last seq %d, got %d", senders*each, last)
+	}
+}
+
+func TestConversations(t *testing.T) {
+	tests := []struct {
+		msg  Message
+		want string
+	}{
+		{Message{Sender: "B", Recipient: "A"}, "dm:A|B"},
+		{Message{Sender: "A", Recipient: "B"}, "dm:A|B"},
+		{Message{Sender: "A", Room: "general", Recipient: "B"}, "room:general"},
+		{Message{Sender: "A", Broadcast: true}, "broadcast"},
+	}
+	for _, tt := range tests {
+		if got := ConversationOf(tt.msg); got != tt.want {
+			t.Errorf("ConversationOf(%+v) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestRangeFillsGaps(t *testing.T) {
+	broker := startBroker(t, WithOfflineQueue(OfflineConfig{Size: 1}), WithHistory(3))
+
+	// both directions of a direct conversation share its numbers
+	sent, err := broker.Send(Message{Sender: "A", Recipient: "B", Content: "1"})
+	if err != nil || sent.Seq != 1 || sent.Conversation != "dm:A|B" {
+		t.Fatalf("unexpected sent message %+v, %v", sent, err)
+	}
+	broker.SendMessage(Message{Sender: "B", Recipient: "A", Content: "2"})
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "3"})
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "4"})
+	waitFor(t, "the offline queue to overflow", func() bool { return broker.Metrics().Expired == 2 })
+
+	// only the last message fits in B's offline queue
+	b := newTestUser("B")
+	broker.RegisterUser(b.ID, b.Recv)
+	m := receive(t, b.Recv)
+	gaps := NewGapDetector()
+	gaps.Resume(m.Conversation, 1)
+	gap, ok := gaps.Observe(m)
+	if !ok || gap != (SeqRange{From: 2, To: 3}) {
+		t.Fatalf("expected a gap 2-3 before %+v, got %+v", m, gap)
+	}
+
+	missed, err := broker.Range("B", m.Conversation, gap.From, gap.To)
+	if err != nil || len(missed) != 2 || missed[0].Content != "2" || missed[1].Content != "3" {
+		t.Errorf("unexpected range %+v, %v", missed, err)
+	}
+	all, err := broker.Range("B", m.Conversation, 1, 0)
+	if err != ErrHistoryGone || len(all) != 3 || all[0].Seq != 2 {
+		t.Errorf("expected messages 2-4 and ErrHistoryGone, got %+v, %v", all, err)
+	}
+	if _, err := broker.Range("C", m.Conversation, 1, 0); err != ErrNotMember {
+		t.Errorf("expected ErrNotMember for an outsider, got %v", err)
+	}
+}
+
+func TestGapDetector(t *testing.T) {
+	gaps := NewGapDetector()
+	tests := []struct {
+		seq  uint64
+		gap  SeqRange
+		miss bool
+	}{
+		{1, SeqRange{}, false},
+		{2, SeqRange{}, false},
+		{5, SeqRange{From: 3, To: 4}, true},
+		{4, SeqRange{}, false}, // late, already reported
+		{5, SeqRange{}, false}, // duplicate
+		{0, SeqRange{}, false}, // event
+		{6, SeqRange{}, false},
+	}
+	for _, tt := range tests {
+		gap, miss := gaps.Observe(Message{Seq: tt.seq, Conversation: "dm:A|B"})
+		if gap != tt.gap || miss != tt.miss {
+			t.Errorf("seq %d: expected %+v %v, got %+v %v", tt.seq, tt.gap, tt.miss, gap, miss)
+		}
+	}
+	if last := gaps.Last("dm:A|B"); last != 6 {
+		t.Errorf("expected last 6, got %d", last)
+	}
+}
+
+func TestStalledSendHoldsOnlyItsConversation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := NewBroker(ctx) // not running yet, so its input fills up
+	for i := 0; i < cap(broker.input); i++ {
+		broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "fill"})
+	}
+	stalled := make(chan struct{})
+	go func() {
+		broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "stalled"})
+		close(stalled)
+	}()
+
+	// the stalled send keeps its number, other conversations stay readable
+	want := uint64(cap(broker.input) + 1)
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		for broker.LastSeq("dm:A|B") != want {
+			time.Sleep(time.Millisecond)
+		}
+		broker.Range("C", "dm:C|D", 1, 0)
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("a stalled send blocked the sequencer")
+	}
+
+	go broker.Run()
+	select {
+	case <-stalled:
+	case <-time.After(time.Second):
+		t.Fatal("the stalled send did not complete once the broker ran")
+	}
+}
+
+func TestRangeFromStart(t *testing.T) {
+	broker := startBroker(t)
+	broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "1"})
+	broker.SendMessage(Message{Sender: "B", Recipient: "A", Content: "2"})
+
+	all, err := broker.Range("A", "dm:A|B", 0, 0)
+	if err != nil || len(all) != 2 || all[0].Seq != 1 {
+		t.Errorf("expected messages 1-2, got %+v, %v", all, err)
+	}
+}
+
+func TestBusLeavesMessagesUnsequenced(t *testing.T) {
+	nodes := newNodes(t, NewMemoryBus(), 2)
+	b := newTestUser("B")
+	nodes[1].RegisterUser(b.ID, b.Recv)
+
+	// each node would number the conversation on its own
+	for _, node := range nodes {
+		sent, err := node.Send(Message{Sender: "A", Recipient: b.ID, Content: "hi"})
+		if err != nil || sent.Seq != 0 {
+			t.Fatalf("expected an unsequenced message, got %+v, %v", sent, err)
+		}
+	}
+	expectExactly(t, "B", b.Recv, "hi", "hi")
+	if _, err := nodes[1].Range(b.ID, "dm:A|B", 0, 0); err != ErrUnsequenced {
+		t.Errorf("expected ErrUnsequenced, got %v", err)
+	}
+}
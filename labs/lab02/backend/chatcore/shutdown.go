@@ -0,0 +1,134 @@
+package chatcore
+
+import (
+	"context"
+	"sync"
+)
+
+// ShutdownReport counts the messages Shutdown could not deliver
+type ShutdownReport struct {
+	// Undelivered is the number of messages left in the queue of each
+	// registered user when its channel was closed, users who got everything
+	// are not listed
+	Undelivered map[string]int
+	// Offline is the number of messages discarded from the offline queues
+	Offline int
+	// Unrouted is the number of sent messages the event loop did not get to
+	// before ctx was done
+	Unrouted int
+}
+
+// Total returns the number of messages lost by the shutdown
+func (r ShutdownReport) Total() int {
+	total := r.Offline + r.Unrouted
+	for _, n := range r.Undelivered {
+		total += n
+	}
+	return total
+}
+
+// shutdownState lets Shutdown wait for the senders in flight
+type shutdownState struct {
+	sendMutex sync.RWMutex // held for reading by senders
+	stopping  chan struct{}
+	stopOnce  sync.Once
+	closed    bool // set under usersMutex once the subscribers are collected
+}
+
+// Shutdown stops the broker: SendMessage returns ErrShuttingDown from now on,
+// messages already sent are delivered to the channels of their recipients
+// until ctx is done, then every channel is closed. It returns ctx.Err() if
+// the deadline passed before all messages were delivered. Run must be running.
+func (b *Broker) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	first := false
+	b.stopOnce.Do(func() {
+		b.sendMutex.Lock()
+		close(b.stopping)
+		b.sendMutex.Unlock()
+		first = true
+	})
+	if !first {
+		return ShutdownReport{}, ErrShuttingDown
+	}
+
+	// no more messages from other nodes, then let the event loop finish
+	b.leaveBus()
+	var err error
+	unrouted := 0
+	select {
+	case <-b.done:
+		b.drainInput()
+	case <-ctx.Done():
+		err = ctx.Err()
+		unrouted = b.discardInput()
+	}
+
+	b.usersMutex.Lock()
+	b.closed = true
+	subs := b.users
+	b.users = make(map[string]*subscriber)
+	b.usersMutex.Unlock()
+
+	for _, sub := range subs {
+		if !sub.drain(ctx) && err == nil {
+			err = ctx.Err()
+		}
+	}
+	report := ShutdownReport{Undelivered: make(map[string]int), Unrouted: unrouted}
+	for _, sub := range subs {
+		before := sub.dropped.Load()
+		sub.halt(true)
+		if n := int(sub.dropped.Load() - before); n > 0 {
+			report.Undelivered[sub.id] = n
+		}
+	}
+	report.Offline = b.offline.clear()
+	return report, err
+}
+
+// ShuttingDown reports whether Shutdown was called
+func (b *Broker) ShuttingDown() bool {
+	select {
+	case <-b.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// accepting returns the error for a call made while the broker is stopped or stopping
+func (b *Broker) accepting() error {
+	if b.ctx.Err() != nil {
+		return ErrBrokerStopped
+	}
+	if b.ShuttingDown() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// drainInput routes the messages the event loop left in the input channel
+func (b *Broker) drainInput() {
+	for {
+		select {
+		case msg := <-b.input:
+			b.route(msg)
+		default:
+			return
+		}
+	}
+}
+
+// discardInput empties the input channel, counting its messages as dropped
+func (b *Broker) discardInput() int {
+	n := 0
+	for {
+		select {
+		case <-b.input:
+			n++
+		default:
+			b.metrics.dropped.Add(uint64(n))
+			return n
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package chatcore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsAcceptedMessages(t *testing.T) {
+	broker := startBroker(t)
+	recv := make(chan Message, 1000)
+	broker.RegisterUser("A", recv)
+
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := broker.SendMessage(Message{Sender: "B", Recipient: "A", Content: "hi"})
+				if err != nil {
+					if !errors.Is(err, ErrShuttingDown) {
+						t.Errorf("expected ErrShuttingDown, got %v", err)
+					}
+					return
+				}
+				if accepted.Add(1) >= 200 {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	report, err := broker.Shutdown(ctx)
+	wg.Wait()
+	if err != nil || report.Total() != 0 {
+		t.Fatalf("expected a clean shutdown, got %+v, %v", report, err)
+	}
+
+	received := 0
+	for range recv {
+		received++
+	}
+	if received != int(accepted.Load()) {
+		t.Errorf("accepted %d messages but delivered %d", accepted.Load(), received)
+	}
+
+	if err := broker.SendMessage(Message{Sender: "B", Recipient: "A"}); err != ErrShuttingDown {
+		t.Errorf("expected ErrShuttingDown after shutdown, got %v", err)
+	}
+	if _, err := broker.Shutdown(ctx); err != ErrShuttingDown {
+		t.Errorf("expected a second shutdown to fail, got %v", err)
+	}
+	late := make(chan Message)
+	broker.RegisterUser("C", late)
+	if _, ok := <-late; ok {
+		t.Error("expected a channel registered after shutdown to be closed")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	broker := startBroker(t)
+	slow := make(chan Message)
+	broker.RegisterUser("A", slow)
+	for range 3 {
+		broker.SendMessage(Message{Sender: "B", Recipient: "A", Content: "hi"})
+	}
+	broker.SendMessage(Message{Sender: "B", Recipient: "C", Content: "later"})
+	waitFor(t, "the messages to be routed", func() bool {
+		stats, _ := broker.Stats("A")
+		return stats.Queued == 2 && broker.Pending("C") == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := broker.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to pass, got %v", err)
+	}
+	if report.Undelivered["A"] != 3 || report.Offline != 1 || report.Total() != 4 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if _, ok := <-slow; ok {
+		t.Error("expected the channel to be closed")
+	}
+	if _, ok := broker.Stats("A"); ok {
+		t.Error("expected A to be unregistered")
+	}
+}
+
+func TestShutdownCountsUnroutedMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := NewBroker(ctx) // the event loop never runs
+	for i := 0; i < 5; i++ {
+		broker.SendMessage(Message{Sender: "A", Recipient: "B", Content: "stuck"})
+	}
+
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	report, err := broker.Shutdown(expired)
+	if !errors.Is(err, context.Canceled) || report.Unrouted != 5 || report.Total() != 5 {
+		t.Errorf("expected 5 unrouted messages, got %+v, %v", report, err)
+	}
+	if m := broker.Metrics(); m.Dropped != 5 {
+		t.Errorf("expected the unrouted messages to count as dropped, got %+v", m)
+	}
+}
@@ -0,0 +1,195 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lab02/chatcore"
+)
+
+// Frame types. Clients send message, read, typing, heartbeat, join and leave
+// frames, the server sends message frames, the events of the broker named
+// after their kind, and error frames.
+const (
+	FrameMessage   = "message"
+	FrameRead      = "read"
+	FrameDelivered = "delivered"
+	FrameTyping    = "typing"
+	FrameHeartbeat = "heartbeat"
+	FrameJoin      = "join"
+	FrameLeave     = "leave"
+	FrameError     = "error"
+)
+
+var errUnknownFrame = errors.New("unknown frame type")
+
+// Frame is the JSON form of a chatcore.Message on the wire. From is always the
+// authenticated user, whatever the client sends.
+type Frame struct {
+	Type      string   `json:"type"`
+	ID        uint64   `json:"id,omitempty"`
+	Ref       uint64   `json:"ref,omitempty"`
+	Seq       uint64   `json:"seq,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Room      string   `json:"room,omitempty"`
+	Content   string   `json:"content,omitempty"`
+	Broadcast bool     `json:"broadcast,omitempty"`
+	Timestamp int64    `json:"timestamp,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	// Conversation is set on chat messages, see chatcore.ConversationOf
+	Conversation string `json:"conversation,omitempty"`
+}
+
+func frameOf(msg chatcore.Message) Frame {
+	typ := string(msg.Kind)
+	if msg.Kind == chatcore.KindMessage {
+		typ = FrameMessage
+	}
+	return Frame{
+		Type:         typ,
+		ID:           msg.ID,
+		Ref:          msg.Ref,
+		Seq:          msg.Seq,
+		From:         msg.Sender,
+		To:           msg.Recipient,
+		Room:         msg.Room,
+		Content:      msg.Content,
+		Broadcast:    msg.Broadcast,
+		Timestamp:    msg.Timestamp,
+		Tags:         msg.Tags,
+		Conversation: msg.Conversation,
+	}
+}
+
+// conn is one WebSocket connection, readLoop passes frames to the broker and
+// writeLoop is the only writer of the socket
+type conn struct {
+	userID string
+	ws     *websocket.Conn
+	recv   chan chatcore.Message
+	errs   chan Frame
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// readLoop handles client frames until the connection fails or goes quiet for pongWait
+func (c *conn) readLoop(broker *chatcore.Broker, pongWait time.Duration) {
+	alive := func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	}
+	c.ws.SetReadLimit(maxFrameSize)
+	c.ws.SetPongHandler(alive)
+	alive("")
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		alive("")
+
+		var frame Frame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			c.fail(errors.New("invalid frame"))
+			continue
+		}
+		if err := c.handle(broker, frame); err != nil {
+			c.fail(err)
+		}
+	}
+}
+
+func (c *conn) handle(broker *chatcore.Broker, frame Frame) error {
+	switch frame.Type {
+	case FrameMessage, "":
+		return broker.SendMessage(chatcore.Message{
+			Sender:    c.userID,
+			Recipient: frame.To,
+			Room:      frame.Room,
+			Content:   frame.Content,
+			Broadcast: frame.Broadcast,
+		})
+	case FrameRead:
+		return broker.MarkRead(c.userID, frame.To, frame.Ref)
+	case FrameTyping:
+		return broker.SendMessage(chatcore.Message{
+			Kind:      chatcore.KindTyping,
+			Sender:    c.userID,
+			Recipient: frame.To,
+			Room:      frame.Room,
+		})
+	case FrameHeartbeat:
+		return broker.Heartbeat(c.userID)
+	case FrameJoin:
+		return broker.JoinRoom(frame.Room, c.userID)
+	case FrameLeave:
+		return broker.LeaveRoom(frame.Room, c.userID)
+	}
+	return errUnknownFrame
+}
+
+// fail reports err to the client, it is dropped if the client is not reading
+func (c *conn) fail(err error) {
+	select {
+	case c.errs <- Frame{Type: FrameError, Error: err.Error()}:
+	default:
+	}
+}
+
+// writeLoop writes the messages of the broker, errors and pings until the
+// connection is closed. The broker closing the channel ends the connection,
+// either because it shuts down or because the client read too slowly.
+func (c *conn) writeLoop(broker *chatcore.Broker, pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		var err error
+		select {
+		case msg, ok := <-c.recv:
+			if !ok {
+				code, reason := websocket.ClosePolicyViolation, "too slow"
+				if broker.ShuttingDown() {
+					code, reason = websocket.CloseGoingAway, "server shutting down"
+				}
+				c.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+				return
+			}
+			err = c.writeJSON(frameOf(msg))
+		case frame := <-c.errs:
+			err = c.writeJSON(frame)
+		case <-ticker.C:
+			err = c.write(websocket.PingMessage, nil)
+		case <-c.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *conn) writeJSON(frame Frame) error {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(frame)
+}
+
+func (c *conn) write(messageType int, data []byte) error {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteMessage(messageType, data)
+}
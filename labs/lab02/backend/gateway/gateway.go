@@ -0,0 +1,163 @@
+// Package gateway exposes a chatcore.Broker over WebSocket. Each connection is
+// authenticated, registered as its user on the broker and exchanges JSON frames.
+package gateway
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lab02/chatcore"
+)
+
+// ErrUnauthorized is returned by an Authenticator that rejects a request
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authenticator resolves the user of an incoming connection
+type Authenticator interface {
+	Authenticate(r *http.Request) (userID string, err error)
+}
+
+// AuthFunc adapts a function to Authenticator
+type AuthFunc func(r *http.Request) (string, error)
+
+// Authenticate calls f
+func (f AuthFunc) Authenticate(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// BearerTokens authenticates requests by a token mapped to its user. The token
+// is read from an "Authorization: Bearer" header or, since browsers cannot set
+// headers on WebSocket requests, from the "token" query parameter.
+func BearerTokens(tokens map[string]string) AuthFunc {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		if userID, ok := tokens[token]; ok && token != "" {
+			return userID, nil
+		}
+		return "", ErrUnauthorized
+	}
+}
+
+// Defaults for the keepalive of a connection
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongWait     = 60 * time.Second
+	writeWait           = 10 * time.Second
+	maxFrameSize        = 64 << 10
+)
+
+// Server is an http.Handler upgrading requests to WebSocket connections on a broker
+type Server struct {
+	broker       *chatcore.Broker
+	auth         Authenticator
+	upgrader     websocket.Upgrader
+	pingInterval time.Duration
+	pongWait     time.Duration
+
+	mutex sync.Mutex
+	conns map[string]*conn // userID -> current connection
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithKeepalive sets how often the server pings and how long it waits for any
+// frame, pongs included, before dropping the connection
+func WithKeepalive(pingInterval, pongWait time.Duration) Option {
+	return func(s *Server) {
+		s.pingInterval = pingInterval
+		s.pongWait = pongWait
+	}
+}
+
+// WithCheckOrigin sets the origin check of the WebSocket handshake, by default
+// the origin must match the host
+func WithCheckOrigin(check func(r *http.Request) bool) Option {
+	return func(s *Server) {
+		s.upgrader.CheckOrigin = check
+	}
+}
+
+// NewServer creates a gateway for broker
+func NewServer(broker *chatcore.Broker, auth Authenticator, opts ...Option) *Server {
+	s := &Server{
+		broker:       broker,
+		auth:         auth,
+		pingInterval: DefaultPingInterval,
+		pongWait:     DefaultPongWait,
+		conns:        make(map[string]*conn),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ServeHTTP authenticates the request, upgrades it and serves the connection
+// until either side closes it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.Authenticate(r)
+	if err != nil || userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		return
+	}
+
+	c := &conn{
+		userID: userID,
+		ws:     ws,
+		recv:   make(chan chatcore.Message, 16),
+		errs:   make(chan Frame, 8),
+		done:   make(chan struct{}),
+	}
+	s.attach(c)
+	defer s.detach(c)
+
+	go c.writeLoop(s.broker, s.pingInterval)
+	c.readLoop(s.broker, s.pongWait)
+}
+
+// attach registers c on the broker, a previous connection of the same user is closed
+func (s *Server) attach(c *conn) {
+	s.mutex.Lock()
+	old := s.conns[c.userID]
+	s.conns[c.userID] = c
+	s.broker.RegisterUser(c.userID, c.recv)
+	s.mutex.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+}
+
+// detach unregisters c unless a newer connection of its user replaced it
+func (s *Server) detach(c *conn) {
+	s.mutex.Lock()
+	if s.conns[c.userID] == c {
+		delete(s.conns, c.userID)
+		s.broker.UnregisterUser(c.userID)
+	}
+	s.mutex.Unlock()
+
+	c.close()
+}
+
+// Connected reports whether a user has an open connection
+func (s *Server) Connected(userID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.conns[userID]
+	return ok
+}
@@ -0,0 +1,204 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lab02/chatcore"
+)
+
+var tokens = map[string]string{"alice-token": "alice", "bob-token": "bob"}
+
+func newTestServer(t *testing.T, opts ...Option) (*Server, *chatcore.Broker, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	broker := chatcore.NewBroker(ctx)
+	go broker.Run()
+
+	gw := NewServer(broker, BearerTokens(tokens), opts...)
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+	return gw, broker, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url, token string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func readFrame(t *testing.T, ws *websocket.Conn) Frame {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var frame Frame
+	if err := ws.ReadJSON(&frame); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return frame
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	_, _, url := newTestServer(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer wrong"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a bad token, got %v", err)
+	}
+	ws, _, err := websocket.DefaultDialer.Dial(url+"?token=bob-token", nil)
+	if err != nil {
+		t.Fatalf("token in the query should be accepted: %v", err)
+	}
+	ws.Close()
+}
+
+func TestDirectMessage(t *testing.T) {
+	gw, _, url := newTestServer(t)
+	alice := dial(t, url, "alice-token")
+	bob := dial(t, url, "bob-token")
+	waitFor(t, "both users to connect", func() bool { return gw.Connected("alice") && gw.Connected("bob") })
+
+	// the sender is the authenticated user, not what the client claims
+	alice.WriteJSON(Frame{Type: FrameMessage, From: "mallory", To: "bob", Content: "hi bob"})
+	frame := readFrame(t, bob)
+	if frame.Type != FrameMessage || frame.From != "alice" || frame.Content != "hi bob" || frame.ID == 0 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+
+	bob.WriteJSON(Frame{Type: FrameRead, To: "alice", Ref: frame.ID})
+	if receipt := readFrame(t, alice); receipt.Type != FrameRead || receipt.Ref != frame.ID || receipt.From != "bob" {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+}
+
+func TestTypingAndPresence(t *testing.T) {
+	gw, broker, url := newTestServer(t)
+	alice := dial(t, url, "alice-token")
+	bob := dial(t, url, "bob-token")
+	waitFor(t, "both users to connect", func() bool { return gw.Connected("alice") && gw.Connected("bob") })
+
+	alice.WriteJSON(Frame{Type: FrameTyping, To: "bob"})
+	if frame := readFrame(t, bob); frame.Type != FrameTyping || frame.From != "alice" {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+
+	registered := broker.Presence("alice").LastSeen
+	alice.WriteJSON(Frame{Type: FrameHeartbeat})
+	waitFor(t, "the heartbeat", func() bool { return broker.Presence("alice").LastSeen.After(registered) })
+
+	alice.Close()
+	waitFor(t, "alice to go offline", func() bool { return broker.Presence("alice").Status == chatcore.Offline })
+}
+
+func TestRoomsAndErrors(t *testing.T) {
+	gw, broker, url := newTestServer(t)
+	alice := dial(t, url, "alice-token")
+	bob := dial(t, url, "bob-token")
+	waitFor(t, "both users to connect", func() bool { return gw.Connected("alice") && gw.Connected("bob") })
+
+	alice.WriteJSON(Frame{Type: FrameMessage, Room: "general", Content: "anyone?"})
+	if frame := readFrame(t, alice); frame.Type != FrameError || frame.Error != chatcore.ErrNotMember.Error() {
+		t.Errorf("expected an error frame, got %+v", frame)
+	}
+	alice.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if frame := readFrame(t, alice); frame.Type != FrameError {
+		t.Errorf("expected an error frame, got %+v", frame)
+	}
+
+	alice.WriteJSON(Frame{Type: FrameJoin, Room: "general"})
+	bob.WriteJSON(Frame{Type: FrameJoin, Room: "general"})
+	waitFor(t, "both users to join", func() bool {
+		members, _ := broker.Members("general")
+		return len(members) == 2
+	})
+	alice.WriteJSON(Frame{Room: "general", Content: "hello room"})
+	if frame := readFrame(t, bob); frame.Room != "general" || frame.Content != "hello room" {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+}
+
+func TestDisconnectUnregisters(t *testing.T) {
+	gw, broker, url := newTestServer(t)
+	alice := dial(t, url, "alice-token")
+	waitFor(t, "alice to connect", func() bool { return gw.Connected("alice") })
+
+	// a second connection replaces the first one
+	second := dial(t, url, "alice-token")
+	alice.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := alice.ReadMessage(); err == nil {
+		t.Error("expected the first connection to be closed")
+	}
+	if _, ok := broker.Stats("alice"); !ok {
+		t.Fatal("closing the replaced connection must not unregister the new one")
+	}
+
+	second.Close()
+	waitFor(t, "alice to be unregistered", func() bool {
+		_, ok := broker.Stats("alice")
+		return !ok && !gw.Connected("alice")
+	})
+}
+
+func TestKeepalive(t *testing.T) {
+	gw, _, url := newTestServer(t, WithKeepalive(20*time.Millisecond, 100*time.Millisecond))
+
+	// a client that reads answers pings and stays connected
+	alice := dial(t, url, "alice-token")
+	go func() {
+		for {
+			if _, _, err := alice.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	// a client that never reads never answers pings
+	dial(t, url, "bob-token")
+
+	time.Sleep(300 * time.Millisecond)
+	if !gw.Connected("alice") {
+		t.Error("a client answering pings should stay connected")
+	}
+	if gw.Connected("bob") {
+		t.Error("a client not answering pings should be dropped")
+	}
+}
+
+func TestShutdownClosesGoingAway(t *testing.T) {
+	gw, broker, url := newTestServer(t)
+	alice := dial(t, url, "alice-token")
+	waitFor(t, "alice to connect", func() bool { return gw.Connected("alice") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := broker.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	alice.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := alice.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected a going away close, got %v", err)
+	}
+}
@@ -1,3 +1,5 @@
 module lab02
 
-go 1.24 
\ No newline at end of file
+go 1.24
+
+require github.com/gorilla/websocket v1.5.3
@@ -0,0 +1,158 @@
+// Package jsonlog implements the append-only files the stores of lab02 persist
+// their changes to, one JSON record per line
+package jsonlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Log appends records to a file as JSON lines and syncs the file after every
+// record
+type Log[T any] struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	corrupt error
+}
+
+// Open opens the log at path, creating it if needed. Replay reports a record
+// other than the last one that cannot be decoded with corrupt.
+func Open[T any](path string, corrupt error) (*Log[T], error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log[T]{path: path, file: file, corrupt: corrupt}, nil
+}
+
+// Append writes rec as one line and syncs the file
+func (l *Log[T]) Append(rec T) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(data); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Replay reads the log from the start. A last line without a newline is what
+// remains of a write interrupted by a crash, it is discarded and cut from the
+// file so the next append starts on a fresh line.
+func (l *Log[T]) Replay(fn func(T) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(l.file, 0, info.Size()))
+
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				return l.file.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var rec T
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%w: line %d: %v", l.corrupt, line, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Compact writes recs to a new file that atomically replaces the log. The new
+// file is opened before the rename, so the log never refers to the replaced
+// one, and the directory is synced so the rename survives a crash.
+func (l *Log[T]) Compact(recs []T) error {
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tmp := l.path + ".tmp"
+	file, err := createSynced(tmp, buf.Bytes())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	return syncDir(filepath.Dir(l.path))
+}
+
+// Close closes the file
+func (l *Log[T]) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// createSynced creates the file at path with data, syncs it and returns it
+// open for appending
+func createSynced(path string, data []byte) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// syncDir makes a rename within dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
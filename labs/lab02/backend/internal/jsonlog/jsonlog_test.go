@@ -0,0 +1,73 @@
+package jsonlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var errCorrupt = errors.New("corrupt test log")
+
+type record struct {
+	N int `json:"n"`
+}
+
+// replayAll returns every record of the log at path
+func replayAll(t *testing.T, path string) ([]record, error) {
+	t.Helper()
+	log, err := Open[record](path, errCorrupt)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer log.Close()
+	var recs []record
+	err = log.Replay(func(rec record) error {
+		recs = append(recs, rec)
+		return nil
+	})
+	return recs, err
+}
+
+func TestAppendReplayCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	log, _ := Open[record](path, errCorrupt)
+	for n := 1; n <= 3; n++ {
+		log.Append(record{n})
+	}
+	if err := log.Compact([]record{{3}}); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	log.Append(record{4})
+	log.Close()
+
+	recs, err := replayAll(t, path)
+	if err != nil || !slices.Equal(recs, []record{{3}, {4}}) {
+		t.Errorf("unexpected records %v, %v", recs, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary file left, got %v", err)
+	}
+}
+
+func TestReplayDamage(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []record
+		err  error
+	}{
+		{"torn last record", "{\"n\":1}\n{\"n\":", []record{{1}}, nil},
+		{"blank lines", "{\"n\":1}\n\n{\"n\":2}\n", []record{{1}, {2}}, nil},
+		{"corrupt record", "{\"n\":1}\ngarbage\n{\"n\":3}\n", []record{{1}}, errCorrupt},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "test.log")
+		os.WriteFile(path, []byte(tt.data), 0o644)
+		recs, err := replayAll(t, path)
+		if !errors.Is(err, tt.err) || !slices.Equal(recs, tt.want) {
+			t.Errorf("%s: expected %v, %v, got %v, %v", tt.name, tt.want, tt.err, recs, err)
+		}
+	}
+}
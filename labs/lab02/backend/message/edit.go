@@ -0,0 +1,64 @@
+package message
+
+import "strings"
+
+// EditMessage replaces the content of a message sent by user, the previous
+// content is kept in the edit history
+func (s *MessageStore) EditMessage(id uint64, user, content string) (Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return Message{}, ErrEmptyContent
+	}
+	return s.update(id, func(msg *Message, now int64) error {
+		if msg.Sender != user {
+			return ErrNotAuthor
+		}
+		if msg.Content == content {
+			return nil
+		}
+		written := msg.Timestamp
+		if msg.EditedAt != 0 {
+			written = msg.EditedAt
+		}
+		msg.Edits = append(msg.Edits, Edit{Content: msg.Content, Timestamp: written})
+		msg.Content = content
+		msg.EditedAt = now
+		return nil
+	})
+}
+
+// DeleteMessage soft deletes a message sent by user. The message keeps its ID
+// and its place in threads, but reads no longer return its content.
+func (s *MessageStore) DeleteMessage(id uint64, user string) error {
+	_, err := s.update(id, func(msg *Message, now int64) error {
+		if msg.Sender != user {
+			return ErrNotAuthor
+		}
+		msg.DeletedAt = now
+		return nil
+	})
+	return err
+}
+
+// update applies fn to a copy of a message that is not deleted, then persists
+// the new version and swaps it in
+func (s *MessageStore) update(id uint64, fn func(msg *Message, now int64) error) (Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	msg, ok := s.getLocked(id)
+	if !ok {
+		return Message{}, ErrMessageNotFound
+	}
+	msg = msg.clone()
+	if msg.Deleted() {
+		return Message{}, ErrMessageDeleted
+	}
+	if err := fn(&msg, s.now().UnixMilli()); err != nil {
+		return Message{}, err
+	}
+	if err := s.persistLocked(msg); err != nil {
+		return Message{}, err
+	}
+	s.shards.set(msg)
+	return msg.redacted(), nil
+}
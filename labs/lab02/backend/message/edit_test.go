@@ -0,0 +1,112 @@
+package message
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEditMessage(t *testing.T) {
+	store := NewMessageStore()
+	clock := time.UnixMilli(1000)
+	store.now = func() time.Time { return clock }
+	msg, _ := store.Append(Message{Sender: "alice", Content: "helo"})
+
+	clock = time.UnixMilli(2000)
+	edited, err := store.EditMessage(msg.ID, "alice", "hello")
+	if err != nil {
+		t.Fatalf("EditMessage failed: %v", err)
+	}
+	clock = time.UnixMilli(3000)
+	edited, _ = store.EditMessage(msg.ID, "alice", "hello!")
+
+	if edited.Content != "hello!" || edited.EditedAt != 3000 {
+		t.Errorf("unexpected edited message: %+v", edited)
+	}
+	want := []Edit{{Content: "helo", Timestamp: 1000}, {Content: "hello", Timestamp: 2000}}
+	if len(edited.Edits) != len(want) || edited.Edits[0] != want[0] || edited.Edits[1] != want[1] {
+		t.Errorf("expected edit history %+v, got %+v", want, edited.Edits)
+	}
+
+	tests := []struct {
+		name    string
+		id      uint64
+		user    string
+		content string
+		err     error
+	}{
+		{"other user", msg.ID, "bob", "hijacked", ErrNotAuthor},
+		{"empty content", msg.ID, "alice", " ", ErrEmptyContent},
+		{"unknown message", 99, "alice", "hi", ErrMessageNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.EditMessage(tt.id, tt.user, tt.content); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	store := NewMessageStore()
+	msg, _ := store.Append(Message{Sender: "alice", Content: "secret"})
+	store.Append(Message{Sender: "alice", Content: "public"})
+
+	if err := store.DeleteMessage(msg.ID, "bob"); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("expected ErrNotAuthor, got %v", err)
+	}
+	if err := store.DeleteMessage(msg.ID, "alice"); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+
+	msgs, _ := store.GetMessages("alice")
+	if len(msgs) != 1 || msgs[0].Content != "public" {
+		t.Errorf("deleted messages should be hidden, got %+v", msgs)
+	}
+	deleted, err := store.GetMessage(msg.ID)
+	if err != nil || !deleted.Deleted() || deleted.Content != "" {
+		t.Errorf("expected a redacted message, got %+v, %v", deleted, err)
+	}
+	page := store.GetPage(Query{IncludeDeleted: true})
+	if len(page.Messages) != 2 || page.Messages[0].Content != "" {
+		t.Errorf("expected the deleted message without content, got %+v", page.Messages)
+	}
+	if _, err := store.EditMessage(msg.ID, "alice", "back"); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("expected ErrMessageDeleted, got %v", err)
+	}
+}
+
+func TestEditsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	log, _ := OpenFileLog(path)
+	store, _ := NewMessageStoreWithBackend(log)
+	first, _ := store.Append(Message{Sender: "alice", Content: "hi"})
+	second, _ := store.Append(Message{Sender: "bob", Content: "oops"})
+	store.EditMessage(first.ID, "alice", "hi all")
+	store.React(first.ID, "bob", "\U0001F44B")
+	store.DeleteMessage(second.ID, "bob")
+	store.Close()
+
+	log, _ = OpenFileLog(path)
+	store, err := NewMessageStoreWithBackend(log)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	defer store.Close()
+
+	if store.Len() != 2 {
+		t.Fatalf("each version should replace the previous one, got %d messages", store.Len())
+	}
+	msg, _ := store.GetMessage(first.ID)
+	if msg.Content != "hi all" || len(msg.Edits) != 1 || len(msg.Reactions["\U0001F44B"]) != 1 {
+		t.Errorf("unexpected replayed message: %+v", msg)
+	}
+	if msg, _ := store.GetMessage(second.ID); !msg.Deleted() {
+		t.Errorf("expected message %d to stay deleted", second.ID)
+	}
+	if next, _ := store.Append(Message{Sender: "bob", Content: "again"}); next.ID != 3 {
+		t.Errorf("IDs should continue after replay, got %d", next.ID)
+	}
+}
@@ -0,0 +1,42 @@
+package message
+
+import (
+	"errors"
+
+	"lab02/internal/jsonlog"
+)
+
+// ErrCorruptLog is returned when a log record other than the last one cannot be decoded
+var ErrCorruptLog = errors.New("corrupt message log")
+
+// Backend durably stores the messages of a MessageStore
+type Backend interface {
+	// Append persists msg, once it returns nil the message survives a restart
+	Append(msg Message) error
+	// Replay calls fn for every stored message in the order they were appended
+	Replay(fn func(Message) error) error
+	Close() error
+}
+
+// Compacter is implemented by backends that can drop the records of messages a
+// store no longer retains
+type Compacter interface {
+	// Compact replaces every record with msgs, the current versions of the
+	// retained messages in ID order
+	Compact(msgs []Message) error
+}
+
+// FileLog is a Backend and Compacter that appends messages to a file as JSON
+// lines and syncs the file after every message
+type FileLog struct {
+	*jsonlog.Log[Message]
+}
+
+// OpenFileLog opens the log at path, creating it if needed
+func OpenFileLog(path string) (*FileLog, error) {
+	log, err := jsonlog.Open[Message](path, ErrCorruptLog)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLog{log}, nil
+}
@@ -0,0 +1,127 @@
+package message
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	log, err := OpenFileLog(path)
+	if err != nil {
+		t.Fatalf("OpenFileLog failed: %v", err)
+	}
+	store, err := NewMessageStoreWithBackend(log)
+	if err != nil {
+		t.Fatalf("NewMessageStoreWithBackend failed: %v", err)
+	}
+	store.AddMessage(Message{Sender: "alice", Content: "hi"})
+	store.AddMessage(Message{Sender: "bob", Content: "line\nbreak"})
+	store.AddMessage(Message{Sender: "alice", Content: "bye"})
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	log, _ = OpenFileLog(path)
+	store, err = NewMessageStoreWithBackend(log, WithCapacity(2))
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	defer store.Close()
+
+	msgs, _ := store.GetMessages("")
+	if len(msgs) != 2 || msgs[0].Content != "line\nbreak" || msgs[1].ID != 3 {
+		t.Errorf("unexpected replayed messages: %+v", msgs)
+	}
+	next, _ := store.Append(Message{Sender: "bob", Content: "again"})
+	if next.ID != 4 {
+		t.Errorf("IDs should continue after replay, got %d", next.ID)
+	}
+}
+
+func TestFileLogTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	data := `{"id":1,"sender":"alice","content":"hi","timestamp":1}` + "\n" + `{"id":2,"sender":"bo`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	log, _ := OpenFileLog(path)
+	store, err := NewMessageStoreWithBackend(log)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("expected the torn record to be skipped, got %d messages", store.Len())
+	}
+	store.AddMessage(Message{Sender: "bob", Content: "retry"})
+	store.Close()
+
+	log, _ = OpenFileLog(path)
+	store, err = NewMessageStoreWithBackend(log)
+	if err != nil {
+		t.Fatalf("second replay failed: %v", err)
+	}
+	defer store.Close()
+	if msgs, _ := store.GetMessages("bob"); len(msgs) != 1 || msgs[0].ID != 2 {
+		t.Errorf("unexpected messages after repair: %+v", msgs)
+	}
+}
+
+func TestFileLogCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	data := `{"id":1,"sender":"alice"}` + "\n" + "garbage\n" + `{"id":3,"sender":"bob"}` + "\n"
+	os.WriteFile(path, []byte(data), 0o644)
+
+	log, _ := OpenFileLog(path)
+	defer log.Close()
+	if _, err := NewMessageStoreWithBackend(log); !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("expected ErrCorruptLog, got %v", err)
+	}
+}
+
+func TestFileLogCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	log, _ := OpenFileLog(path)
+	store, err := NewMessageStoreWithBackend(log, WithCapacity(3))
+	if err != nil {
+		t.Fatalf("NewMessageStoreWithBackend failed: %v", err)
+	}
+
+	var maxSize int64
+	for i := 0; i < 100; i++ {
+		msg, _ := store.Append(Message{Sender: "alice", Content: "hello"})
+		store.React(msg.ID, "bob", "\U0001F44D")
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		maxSize = max(maxSize, info.Size())
+	}
+	store.Close()
+
+	// compacted once it holds more than 6 records of under 100 bytes each,
+	// without compaction it would hold 200
+	if maxSize > 1000 {
+		t.Errorf("expected the log to stay bounded, it grew to %d bytes", maxSize)
+	}
+
+	log, _ = OpenFileLog(path)
+	store, err = NewMessageStoreWithBackend(log, WithCapacity(3))
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	defer store.Close()
+	msgs, _ := store.GetMessages("")
+	if len(msgs) != 3 || msgs[0].ID != 98 || len(msgs[2].Reactions) != 1 {
+		t.Errorf("unexpected messages after compaction: %+v", msgs)
+	}
+	if next, _ := store.Append(Message{Sender: "bob", Content: "again"}); next.ID != 101 {
+		t.Errorf("IDs should continue after compaction, got %d", next.ID)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary file left, got %v", err)
+	}
+}
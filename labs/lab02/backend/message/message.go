@@ -2,43 +2,290 @@ package message
 
 import (
 	"errors"
+	"slices"
 	"sync"
+	"time"
+)
+
+// Errors returned by MessageStore
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrNotAuthor       = errors.New("only the sender can change a message")
+	ErrMessageDeleted  = errors.New("message was deleted")
+	ErrEmptyContent    = errors.New("message content cannot be empty")
+	ErrInvalidReaction = errors.New("invalid reaction")
 )
 
 // Message represents a chat message
-// TODO: Add more fields if needed
 
 type Message struct {
-	Sender    string
-	Content   string
-	Timestamp int64
+	// ID is assigned by the store, IDs increase in the order messages are added
+	ID     uint64 `json:"id"`
+	Sender string `json:"sender"`
+	// Content is the text of the message
+	Content string `json:"content"`
+	// Timestamp is in Unix milliseconds, AddMessage sets it if it is zero
+	Timestamp int64 `json:"timestamp"`
+	// ReplyTo is the ID of the message this one answers, 0 if none
+	ReplyTo uint64 `json:"reply_to,omitempty"`
+	// EditedAt is the time of the last edit in Unix milliseconds, 0 if never edited
+	EditedAt int64 `json:"edited_at,omitempty"`
+	// Edits holds the previous versions of the content, oldest first
+	Edits []Edit `json:"edits,omitempty"`
+	// DeletedAt is the time the message was deleted in Unix milliseconds, 0 if it was not
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	// Reactions maps each emoji to the users who reacted with it, sorted by name
+	Reactions map[string][]string `json:"reactions,omitempty"`
+}
+
+// Edit is a previous version of a message content
+type Edit struct {
+	Content string `json:"content"`
+	// Timestamp is when this version was written, in Unix milliseconds
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Deleted reports whether the message was deleted
+func (m Message) Deleted() bool {
+	return m.DeletedAt != 0
+}
+
+// clone returns a copy of the message that shares no memory with the original
+func (m Message) clone() Message {
+	m.Edits = slices.Clone(m.Edits)
+	if m.Reactions != nil {
+		reactions := make(map[string][]string, len(m.Reactions))
+		for emoji, users := range m.Reactions {
+			reactions[emoji] = slices.Clone(users)
+		}
+		m.Reactions = reactions
+	}
+	return m
+}
+
+// redacted returns the copy handed out for a deleted message, which keeps its
+// place in the history but not what it said
+func (m Message) redacted() Message {
+	if !m.Deleted() {
+		return m.clone()
+	}
+	m.Content = ""
+	m.Edits = nil
+	m.Reactions = nil
+	return m
 }
 
 // MessageStore stores chat messages
-// Contains a slice of messages and a mutex for concurrency
+// Contains a ring of message refs in ID order guarded by a mutex, and the
+// messages themselves sharded by sender so per-user reads do not contend on
+// that mutex. Only those reads are sharded, every write takes the mutex.
 
 type MessageStore struct {
-	messages []Message
+	messages ring
 	mutex    sync.RWMutex
-	// TODO: Add more fields if needed
+	shards   *shards
+	nextID   uint64
+	backend  Backend
+	// records counts the records in the backend since it was last compacted
+	records int
+	now     func() time.Time
+}
+
+// Option configures a MessageStore
+type Option func(*MessageStore)
+
+// WithCapacity bounds the store to the n most recent messages, older ones are
+// dropped as new ones arrive, n <= 0 keeps every message
+func WithCapacity(n int) Option {
+	return func(s *MessageStore) {
+		s.messages = newRing(n)
+	}
 }
 
-// NewMessageStore creates a new MessageStore
-func NewMessageStore() *MessageStore {
-	// TODO: Initialize MessageStore fields
-	return &MessageStore{
-		messages: make([]Message, 0, 100),
+// NewMessageStore creates a new in-memory MessageStore
+func NewMessageStore(opts ...Option) *MessageStore {
+	s := &MessageStore{
+		messages: newRing(0),
+		shards:   newShards(),
+		nextID:   1,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewMessageStoreWithBackend creates a MessageStore that persists every message
+// to backend, replaying the messages the backend already holds. With
+// WithCapacity only the most recent messages are kept in memory, and a backend
+// that is a Compacter is compacted once it holds twice as many records.
+func NewMessageStoreWithBackend(backend Backend, opts ...Option) (*MessageStore, error) {
+	s := NewMessageStore(opts...)
+	err := backend.Replay(func(msg Message) error {
+		s.replayLocked(msg)
+		s.records++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.backend = backend
+	return s, nil
+}
+
+// replayLocked applies a record of the backend, later versions of a message
+// replace earlier ones and versions of messages no longer retained are ignored
+func (s *MessageStore) replayLocked(msg Message) {
+	if msg.ID >= s.nextID {
+		s.pushLocked(msg)
+		s.nextID = msg.ID + 1
+		return
+	}
+	if i, ok := s.messages.find(msg.ID); ok && s.messages.at(i).sender == msg.Sender {
+		s.shards.set(msg)
 	}
 }
 
+// pushLocked stores a new message, dropping the oldest one if the store is full
+func (s *MessageStore) pushLocked(msg Message) {
+	if dropped, ok := s.messages.push(ref{id: msg.ID, sender: msg.Sender}); ok {
+		s.shards.drop(dropped)
+	}
+	s.shards.add(msg)
+}
+
+// getLocked returns the stored version of the message with the given ID
+func (s *MessageStore) getLocked(id uint64) (Message, bool) {
+	i, ok := s.messages.find(id)
+	if !ok {
+		return Message{}, false
+	}
+	return s.shards.get(s.messages.at(i)), true
+}
+
 // AddMessage stores a new message
 func (s *MessageStore) AddMessage(msg Message) error {
-	// TODO: Add message to storage (concurrent safe)
+	_, err := s.Append(msg)
+	return err
+}
+
+// Append stores a new message and returns it with its assigned ID and
+// timestamp. Edits, deletion and reactions are ignored, use the methods of the
+// store to change a message once it was added. A reply must answer a message
+// that is still retained. Appends are serialized by the store mutex.
+func (s *MessageStore) Append(msg Message) (Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if msg.ReplyTo != 0 {
+		if _, ok := s.messages.find(msg.ReplyTo); !ok {
+			return Message{}, ErrMessageNotFound
+		}
+	}
+
+	msg = Message{
+		ID:        s.nextID,
+		Sender:    msg.Sender,
+		Content:   msg.Content,
+		Timestamp: msg.Timestamp,
+		ReplyTo:   msg.ReplyTo,
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = s.now().UnixMilli()
+	}
+	if err := s.persistLocked(msg); err != nil {
+		return Message{}, err
+	}
+	s.pushLocked(msg)
+	s.nextID++
+	return msg.clone(), nil
+}
+
+// persistLocked writes a message version to the backend, if any, compacting
+// the backend first when it is due
+func (s *MessageStore) persistLocked(msg Message) error {
+	if s.backend == nil {
+		return nil
+	}
+	if err := s.compactLocked(); err != nil {
+		return err
+	}
+	if err := s.backend.Append(msg); err != nil {
+		return err
+	}
+	s.records++
 	return nil
 }
 
-// GetMessages retrieves messages (optionally by user)
+// compactLocked replaces the records of the backend with the retained
+// messages once it holds more than twice the capacity of the store
+func (s *MessageStore) compactLocked() error {
+	compacter, ok := s.backend.(Compacter)
+	if !ok || s.messages.limit == 0 || s.records <= 2*s.messages.limit {
+		return nil
+	}
+	msgs := make([]Message, s.messages.len())
+	for i := range msgs {
+		msgs[i] = s.shards.get(s.messages.at(i))
+	}
+	if err := compacter.Compact(msgs); err != nil {
+		return err
+	}
+	s.records = len(msgs)
+	return nil
+}
+
+// GetMessages retrieves messages (optionally by user), oldest first, deleted
+// messages are left out. The messages of one user are read from their shard in
+// time proportional to their number.
 func (s *MessageStore) GetMessages(user string) ([]Message, error) {
-	// TODO: Retrieve messages (all or by user)
-	return nil, errors.New("not implemented")
+	visible := func(msg Message) bool { return !msg.Deleted() }
+	if user != "" {
+		return s.shards.messagesOf(user, visible), nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]Message, 0, s.messages.len())
+	for i := 0; i < s.messages.len(); i++ {
+		if msg := s.shards.get(s.messages.at(i)); visible(msg) {
+			result = append(result, msg.clone())
+		}
+	}
+	return result, nil
+}
+
+// GetMessage returns the message with the given ID, a deleted message is
+// returned without its content
+func (s *MessageStore) GetMessage(id uint64) (Message, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	msg, ok := s.getLocked(id)
+	if !ok {
+		return Message{}, ErrMessageNotFound
+	}
+	return msg.redacted(), nil
+}
+
+// Len returns the number of messages currently retained
+func (s *MessageStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.messages.len()
+}
+
+// Close closes the backend of the store, if any
+func (s *MessageStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.backend == nil {
+		return nil
+	}
+	err := s.backend.Close()
+	s.backend = nil
+	return err
 }
@@ -42,3 +42,31 @@ func TestGetMessagesByUser(t *testing.T) {
 		t.Errorf("expected 2 messages for alice, got %d", len(msgs))
 	}
 }
+
+func TestAppendAssignsIDs(t *testing.T) {
+	store := NewMessageStore()
+	first, _ := store.Append(Message{Sender: "alice", Content: "hi"})
+	second, _ := store.Append(Message{Sender: "bob", Content: "hello", Timestamp: 42})
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("expected IDs 1 and 2, got %d and %d", first.ID, second.ID)
+	}
+	if first.Timestamp == 0 || second.Timestamp != 42 {
+		t.Errorf("unexpected timestamps %d and %d", first.Timestamp, second.Timestamp)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	store := NewMessageStore(WithCapacity(3))
+	for i := 1; i <= 5; i++ {
+		store.AddMessage(Message{Sender: "user", Content: "msg", Timestamp: int64(i)})
+	}
+	msgs, _ := store.GetMessages("")
+	if len(msgs) != 3 || store.Len() != 3 {
+		t.Fatalf("expected 3 retained messages, got %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		if msg.ID != uint64(i+3) {
+			t.Errorf("position %d: expected ID %d, got %d", i, i+3, msg.ID)
+		}
+	}
+}
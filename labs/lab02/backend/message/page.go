@@ -0,0 +1,145 @@
+package message
+
+import "slices"
+
+// DefaultPageSize is the page size used when a Query sets no limit
+const DefaultPageSize = 50
+
+// Query selects a page of messages, zero-valued fields do not filter anything.
+// Cursors are exclusive: a page after message 10 starts at message 11.
+type Query struct {
+	// Sender keeps only messages of this user
+	Sender string
+	// AfterID and BeforeID keep messages with IDs strictly between them
+	AfterID  uint64
+	BeforeID uint64
+	// After and Before keep messages with timestamps strictly between them
+	After  int64
+	Before int64
+	// Limit is the maximum number of messages returned, DefaultPageSize if <= 0
+	Limit int
+	// IncludeDeleted keeps deleted messages, without their content
+	IncludeDeleted bool
+}
+
+// forward reports whether the query pages from its lower bound onwards, by
+// default pages end at the upper bound so the newest messages come first
+func (q Query) forward() bool {
+	return (q.AfterID != 0 || q.After != 0) && q.BeforeID == 0 && q.Before == 0
+}
+
+func (q Query) matches(msg Message) bool {
+	if msg.Deleted() && !q.IncludeDeleted {
+		return false
+	}
+	if q.Sender != "" && msg.Sender != q.Sender {
+		return false
+	}
+	if q.After != 0 && msg.Timestamp <= q.After {
+		return false
+	}
+	if q.Before != 0 && msg.Timestamp >= q.Before {
+		return false
+	}
+	return true
+}
+
+// Page is a slice of the message history
+type Page struct {
+	// Messages are ordered oldest first
+	Messages []Message
+	// HasMore reports whether more messages match beyond this page, older ones
+	// for a backward page and newer ones for a forward page
+	HasMore bool
+}
+
+// Older returns the query for the page before p, it keeps the filters of q
+func (p Page) Older(q Query) Query {
+	if len(p.Messages) > 0 {
+		q.BeforeID = p.Messages[0].ID
+		q.AfterID, q.After = 0, 0
+	}
+	return q
+}
+
+// Newer returns the query for the page after p, it keeps the filters of q
+func (p Page) Newer(q Query) Query {
+	if len(p.Messages) > 0 {
+		q.AfterID = p.Messages[len(p.Messages)-1].ID
+		q.BeforeID, q.Before = 0, 0
+	}
+	return q
+}
+
+// GetPage returns up to q.Limit messages matching q. A query with only a lower
+// bound (AfterID or After) returns the oldest messages after it, any other
+// query the newest messages before its upper bound. A query with a Sender only
+// reads the messages of that user.
+func (s *MessageStore) GetPage(q Query) Page {
+	if q.Sender != "" {
+		sh := s.shards.of(q.Sender)
+		sh.mutex.RLock()
+		defer sh.mutex.RUnlock()
+
+		msgs := sh.bySender[q.Sender]
+		lo, hi := 0, len(msgs)
+		if q.AfterID != 0 {
+			lo, _ = slices.BinarySearchFunc(msgs, q.AfterID+1, compareID)
+		}
+		if q.BeforeID != 0 {
+			hi, _ = slices.BinarySearchFunc(msgs, q.BeforeID, compareID)
+		}
+		return q.page(lo, hi, func(i int) Message { return msgs[i] })
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	lo := 0
+	if q.AfterID != 0 {
+		lo = s.messages.search(q.AfterID + 1)
+	}
+	hi := s.messages.len()
+	if q.BeforeID != 0 {
+		hi = s.messages.search(q.BeforeID)
+	}
+	return q.page(lo, hi, func(i int) Message { return s.shards.get(s.messages.at(i)) })
+}
+
+// page collects the matching messages among at(lo) to at(hi-1)
+func (q Query) page(lo, hi int, at func(int) Message) Page {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	var page Page
+	if q.forward() {
+		for i := lo; i < hi; i++ {
+			msg := at(i)
+			if !q.matches(msg) {
+				continue
+			}
+			if len(page.Messages) == limit {
+				page.HasMore = true
+				break
+			}
+			page.Messages = append(page.Messages, msg.redacted())
+		}
+		return page
+	}
+
+	for i := hi - 1; i >= lo; i-- {
+		msg := at(i)
+		if !q.matches(msg) {
+			continue
+		}
+		if len(page.Messages) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Messages = append(page.Messages, msg.redacted())
+	}
+	slices.Reverse(page.Messages)
+	return page
+}
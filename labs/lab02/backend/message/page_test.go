@@ -0,0 +1,104 @@
+package message
+
+import (
+	"testing"
+)
+
+func newPagedStore(t *testing.T, opts ...Option) *MessageStore {
+	t.Helper()
+	store := NewMessageStore(opts...)
+	for i := 1; i <= 10; i++ {
+		sender := "alice"
+		if i%2 == 0 {
+			sender = "bob"
+		}
+		store.AddMessage(Message{Sender: sender, Content: "msg", Timestamp: int64(i * 100)})
+	}
+	return store
+}
+
+func ids(msgs []Message) []uint64 {
+	result := make([]uint64, len(msgs))
+	for i, msg := range msgs {
+		result[i] = msg.ID
+	}
+	return result
+}
+
+func TestGetPage(t *testing.T) {
+	store := newPagedStore(t)
+
+	tests := []struct {
+		name    string
+		query   Query
+		want    []uint64
+		hasMore bool
+	}{
+		{"latest", Query{Limit: 3}, []uint64{8, 9, 10}, true},
+		{"default limit", Query{}, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"before ID", Query{BeforeID: 8, Limit: 3}, []uint64{5, 6, 7}, true},
+		{"after ID", Query{AfterID: 2, Limit: 3}, []uint64{3, 4, 5}, true},
+		{"after ID to the end", Query{AfterID: 8, Limit: 3}, []uint64{9, 10}, false},
+		{"between IDs", Query{AfterID: 2, BeforeID: 6}, []uint64{3, 4, 5}, false},
+		{"before timestamp", Query{Before: 300, Limit: 5}, []uint64{1, 2}, false},
+		{"after timestamp", Query{After: 800}, []uint64{9, 10}, false},
+		{"by sender", Query{Sender: "bob", Limit: 2}, []uint64{8, 10}, true},
+		{"by sender after ID", Query{Sender: "alice", AfterID: 4, Limit: 2}, []uint64{5, 7}, true},
+		{"no match", Query{Sender: "carol"}, []uint64{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := store.GetPage(tt.query)
+			got := ids(page.Messages)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got IDs %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got IDs %v, want %v", got, tt.want)
+				}
+			}
+			if page.HasMore != tt.hasMore {
+				t.Errorf("HasMore = %v, want %v", page.HasMore, tt.hasMore)
+			}
+		})
+	}
+}
+
+func TestPageCursors(t *testing.T) {
+	store := newPagedStore(t)
+
+	// Walk back through the history three messages at a time
+	var seen []uint64
+	q := Query{Limit: 3}
+	for {
+		page := store.GetPage(q)
+		seen = append(ids(page.Messages), seen...)
+		if !page.HasMore {
+			break
+		}
+		q = page.Older(q)
+	}
+	if len(seen) != 10 || seen[0] != 1 || seen[9] != 10 {
+		t.Errorf("walking backwards saw %v", seen)
+	}
+
+	page := store.GetPage(Query{AfterID: 3, Limit: 2})
+	next := store.GetPage(page.Newer(Query{AfterID: 3, Limit: 2}))
+	if got := ids(next.Messages); len(got) != 2 || got[0] != 6 {
+		t.Errorf("next page after %v is %v", ids(page.Messages), got)
+	}
+}
+
+func TestGetPageWithCapacity(t *testing.T) {
+	store := newPagedStore(t, WithCapacity(4))
+
+	page := store.GetPage(Query{BeforeID: 9, Limit: 5})
+	if got := ids(page.Messages); len(got) != 2 || got[0] != 7 || page.HasMore {
+		t.Errorf("expected messages dropped by the ring to be gone, got %v (has more %v)", got, page.HasMore)
+	}
+	page = store.GetPage(Query{AfterID: 2, Limit: 2})
+	if got := ids(page.Messages); len(got) != 2 || got[0] != 7 || got[1] != 8 || !page.HasMore {
+		t.Errorf("unexpected page after an evicted cursor: %v", got)
+	}
+}
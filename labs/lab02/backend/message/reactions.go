@@ -0,0 +1,64 @@
+package message
+
+import (
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxReactionLength bounds a reaction in bytes, long enough for emoji ZWJ sequences
+const maxReactionLength = 64
+
+// React adds the reaction emoji of user to a message, reacting twice with the
+// same emoji has no further effect
+func (s *MessageStore) React(id uint64, user, emoji string) (Message, error) {
+	if !validReaction(emoji) || user == "" {
+		return Message{}, ErrInvalidReaction
+	}
+	return s.update(id, func(msg *Message, _ int64) error {
+		users := msg.Reactions[emoji]
+		i, found := slices.BinarySearch(users, user)
+		if found {
+			return nil
+		}
+		if msg.Reactions == nil {
+			msg.Reactions = make(map[string][]string)
+		}
+		msg.Reactions[emoji] = slices.Insert(users, i, user)
+		return nil
+	})
+}
+
+// Unreact removes the reaction emoji of user from a message, if there is one
+func (s *MessageStore) Unreact(id uint64, user, emoji string) (Message, error) {
+	return s.update(id, func(msg *Message, _ int64) error {
+		users := msg.Reactions[emoji]
+		i, found := slices.BinarySearch(users, user)
+		if !found {
+			return nil
+		}
+		if users = slices.Delete(users, i, i+1); len(users) > 0 {
+			msg.Reactions[emoji] = users
+			return nil
+		}
+		delete(msg.Reactions, emoji)
+		if len(msg.Reactions) == 0 {
+			msg.Reactions = nil
+		}
+		return nil
+	})
+}
+
+// validReaction reports whether emoji can be used as a reaction: a short
+// string without spaces or control characters
+func validReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > maxReactionLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
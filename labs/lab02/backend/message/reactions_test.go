@@ -0,0 +1,60 @@
+package message
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestReact(t *testing.T) {
+	const thumbs, party = "\U0001F44D", "\U0001F389"
+	store := NewMessageStore()
+	msg, _ := store.Append(Message{Sender: "alice", Content: "shipped"})
+
+	store.React(msg.ID, "carol", thumbs)
+	store.React(msg.ID, "bob", thumbs)
+	store.React(msg.ID, "bob", thumbs)
+	got, err := store.React(msg.ID, "bob", party)
+	if err != nil {
+		t.Fatalf("React failed: %v", err)
+	}
+	if !slices.Equal(got.Reactions[thumbs], []string{"bob", "carol"}) || len(got.Reactions[party]) != 1 {
+		t.Errorf("unexpected reactions: %v", got.Reactions)
+	}
+
+	store.Unreact(msg.ID, "bob", party)
+	got, _ = store.Unreact(msg.ID, "dave", thumbs)
+	if _, ok := got.Reactions[party]; ok || len(got.Reactions[thumbs]) != 2 {
+		t.Errorf("unexpected reactions after removal: %v", got.Reactions)
+	}
+
+	// copies handed out must not alias the stored reactions
+	got.Reactions[thumbs][0] = "mallory"
+	if stored, _ := store.GetMessage(msg.ID); stored.Reactions[thumbs][0] != "bob" {
+		t.Errorf("stored reactions were modified through a copy: %v", stored.Reactions)
+	}
+}
+
+func TestReactInvalid(t *testing.T) {
+	store := NewMessageStore()
+	msg, _ := store.Append(Message{Sender: "alice", Content: "hi"})
+
+	tests := []struct {
+		name  string
+		user  string
+		emoji string
+	}{
+		{"empty emoji", "bob", ""},
+		{"whitespace", "bob", "\U0001F44D \U0001F44D"},
+		{"control character", "bob", "\x07"},
+		{"too long", "bob", string(make([]byte, maxReactionLength+1))},
+		{"no user", "", "\U0001F44D"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.React(msg.ID, tt.user, tt.emoji); !errors.Is(err, ErrInvalidReaction) {
+				t.Errorf("expected ErrInvalidReaction, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package message
+
+import "sort"
+
+// ref locates a message: the ring orders messages by ID and the shard of the
+// sender holds the message itself
+type ref struct {
+	id     uint64
+	sender string
+}
+
+// ring holds message refs in the order they were added, with a limit it drops
+// the oldest ref once full and without one it grows like a slice
+type ring struct {
+	items []ref
+	// head is the index of the oldest ref once the ring is full
+	head  int
+	limit int
+}
+
+func newRing(limit int) ring {
+	if limit < 0 {
+		limit = 0
+	}
+	return ring{limit: limit}
+}
+
+func (r *ring) len() int {
+	return len(r.items)
+}
+
+// at returns the i-th oldest ref
+func (r *ring) at(i int) ref {
+	return r.items[(r.head+i)%len(r.items)]
+}
+
+// push appends item and returns the oldest ref if it was dropped for it
+func (r *ring) push(item ref) (ref, bool) {
+	if r.limit == 0 || len(r.items) < r.limit {
+		r.items = append(r.items, item)
+		return ref{}, false
+	}
+	dropped := r.items[r.head]
+	r.items[r.head] = item
+	r.head = (r.head + 1) % r.limit
+	return dropped, true
+}
+
+// search returns the index of the first ref with an ID of at least id
+func (r *ring) search(id uint64) int {
+	return sort.Search(r.len(), func(i int) bool {
+		return r.at(i).id >= id
+	})
+}
+
+// find returns the index of the ref with the given ID
+func (r *ring) find(id uint64) (int, bool) {
+	i := r.search(id)
+	return i, i < r.len() && r.at(i).id == id
+}
@@ -0,0 +1,108 @@
+package message
+
+import (
+	"hash/maphash"
+	"slices"
+	"sync"
+)
+
+// shardCount is the number of shards messages are spread over by sender
+const shardCount = 32
+
+// shard holds the messages of the senders hashed to it, each sender's
+// messages ordered by ID. Readers of one sender only lock its shard.
+type shard struct {
+	mutex    sync.RWMutex
+	bySender map[string][]Message
+}
+
+// shards spreads senders over shardCount shards
+type shards struct {
+	seed maphash.Seed
+	list [shardCount]shard
+}
+
+func newShards() *shards {
+	s := &shards{seed: maphash.MakeSeed()}
+	for i := range s.list {
+		s.list[i].bySender = make(map[string][]Message)
+	}
+	return s
+}
+
+// of returns the shard holding the messages of sender
+func (s *shards) of(sender string) *shard {
+	return &s.list[maphash.String(s.seed, sender)%shardCount]
+}
+
+// get returns the message r refers to
+func (s *shards) get(r ref) Message {
+	sh := s.of(r.sender)
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	msgs := sh.bySender[r.sender]
+	i, _ := slices.BinarySearchFunc(msgs, r.id, compareID)
+	return msgs[i]
+}
+
+// add appends msg, whose ID is greater than any stored for its sender
+func (s *shards) add(msg Message) {
+	sh := s.of(msg.Sender)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	sh.bySender[msg.Sender] = append(sh.bySender[msg.Sender], msg)
+}
+
+// set replaces the stored version of msg
+func (s *shards) set(msg Message) {
+	sh := s.of(msg.Sender)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	msgs := sh.bySender[msg.Sender]
+	i, _ := slices.BinarySearchFunc(msgs, msg.ID, compareID)
+	msgs[i] = msg
+}
+
+// drop removes the message r refers to, which is the oldest of its sender
+func (s *shards) drop(r ref) {
+	sh := s.of(r.sender)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	msgs := sh.bySender[r.sender]
+	if len(msgs) == 1 {
+		delete(sh.bySender, r.sender)
+		return
+	}
+	// clear the slot so the dropped message can be collected
+	msgs[0] = Message{}
+	sh.bySender[r.sender] = msgs[1:]
+}
+
+// messagesOf returns copies of the messages of sender that match keep
+func (s *shards) messagesOf(sender string, keep func(Message) bool) []Message {
+	sh := s.of(sender)
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	msgs := sh.bySender[sender]
+	result := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if keep(msg) {
+			result = append(result, msg.clone())
+		}
+	}
+	return result
+}
+
+func compareID(msg Message, id uint64) int {
+	switch {
+	case msg.ID < id:
+		return -1
+	case msg.ID > id:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,205 @@
+package message
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetMessagesByUserWithCapacity(t *testing.T) {
+	store := NewMessageStore(WithCapacity(5))
+	senders := []string{"alice", "bob", "carol"}
+	for i := 0; i < 12; i++ {
+		store.AddMessage(Message{Sender: senders[i%3], Content: fmt.Sprint(i)})
+	}
+
+	// the ring keeps IDs 8 to 12: bob 8 and 11, carol 9 and 12, alice 10
+	want := map[string][]uint64{"alice": {10}, "bob": {8, 11}, "carol": {9, 12}}
+	for sender, ids := range want {
+		msgs, _ := store.GetMessages(sender)
+		if len(msgs) != len(ids) {
+			t.Fatalf("%s: expected %d messages, got %d", sender, len(ids), len(msgs))
+		}
+		for i, msg := range msgs {
+			if msg.ID != ids[i] || msg.Sender != sender {
+				t.Errorf("%s: position %d: expected ID %d, got %+v", sender, i, ids[i], msg)
+			}
+		}
+	}
+
+	store.DeleteMessage(10, "alice")
+	if msgs, _ := store.GetMessages("alice"); len(msgs) != 0 {
+		t.Errorf("expected alice's only message to be deleted, got %+v", msgs)
+	}
+}
+
+func TestConcurrentReadersAndWriters(t *testing.T) {
+	store := NewMessageStore(WithCapacity(200))
+	const writers, perWriter = 8, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				store.AddMessage(Message{Sender: fmt.Sprintf("user%d", w), Content: "msg"})
+			}
+		}(w)
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			sender := fmt.Sprintf("user%d", r)
+			for i := 0; i < perWriter; i++ {
+				msgs, _ := store.GetMessages(sender)
+				for i, msg := range msgs {
+					if msg.Sender != sender || (i > 0 && msg.ID <= msgs[i-1].ID) {
+						t.Errorf("unexpected messages for %s: %+v", sender, msgs)
+						return
+					}
+				}
+				store.GetPage(Query{Limit: 10})
+			}
+		}(r)
+	}
+	wg.Wait()
+	readers.Wait()
+
+	total := 0
+	for w := 0; w < writers; w++ {
+		msgs, _ := store.GetMessages(fmt.Sprintf("user%d", w))
+		total += len(msgs)
+	}
+	if total != store.Len() {
+		t.Errorf("per-user messages add up to %d, the store holds %d", total, store.Len())
+	}
+}
+
+// benchStore is the part of a store the read benchmarks use
+type benchStore interface {
+	AddMessage(msg Message) error
+	GetMessages(user string) ([]Message, error)
+}
+
+// scanStore is the baseline the shards are measured against: a single lock
+// over all messages, scanned in full for the messages of one user
+type scanStore struct {
+	mutex    sync.RWMutex
+	messages []Message
+}
+
+func (s *scanStore) AddMessage(msg Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	msg.ID = uint64(len(s.messages) + 1)
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *scanStore) GetMessages(user string) ([]Message, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []Message
+	for _, msg := range s.messages {
+		if msg.Sender == user && !msg.Deleted() {
+			result = append(result, msg.clone())
+		}
+	}
+	return result, nil
+}
+
+// fillBenchStore adds 100000 messages from 1000 senders
+func fillBenchStore(store benchStore) {
+	for i := 0; i < 100000; i++ {
+		store.AddMessage(Message{Sender: fmt.Sprintf("user%d", i%1000), Content: "msg"})
+	}
+}
+
+// newBenchStore returns a store of 100000 messages from 1000 senders
+func newBenchStore(b *testing.B) *MessageStore {
+	b.Helper()
+	store := NewMessageStore()
+	fillBenchStore(store)
+	return store
+}
+
+// startWriters appends messages from n goroutines until the returned function is called
+func startWriters(store benchStore, n int) (stop func()) {
+	var wg sync.WaitGroup
+	var done atomic.Bool
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; !done.Load(); i++ {
+				store.AddMessage(Message{Sender: fmt.Sprintf("writer%d", w), Content: "msg"})
+			}
+		}(w)
+	}
+	return func() {
+		done.Store(true)
+		wg.Wait()
+	}
+}
+
+func BenchmarkGetMessagesByUser(b *testing.B) {
+	stores := []struct {
+		name string
+		new  func() benchStore
+	}{
+		{"sharded", func() benchStore { return NewMessageStore() }},
+		{"scan", func() benchStore { return &scanStore{} }},
+	}
+	for _, tt := range stores {
+		for _, writers := range []int{0, 4} {
+			b.Run(fmt.Sprintf("store=%s/writers=%d", tt.name, writers), func(b *testing.B) {
+				store := tt.new()
+				fillBenchStore(store)
+				stop := startWriters(store, writers)
+				defer stop()
+
+				var next atomic.Int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					sender := fmt.Sprintf("user%d", next.Add(1)%1000)
+					for pb.Next() {
+						store.GetMessages(sender)
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkAppendWithReaders(b *testing.B) {
+	store := newBenchStore(b)
+	var wg sync.WaitGroup
+	var done atomic.Bool
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			sender := fmt.Sprintf("user%d", r)
+			for !done.Load() {
+				store.GetMessages(sender)
+			}
+		}(r)
+	}
+	defer func() {
+		done.Store(true)
+		wg.Wait()
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			store.AddMessage(Message{Sender: "writer", Content: "msg"})
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package message
+
+// GetThread returns the thread containing the message with the given ID: the
+// message it ultimately replies to followed by every direct or indirect reply,
+// oldest first. Deleted messages are kept without their content so the
+// replies to them still make sense. The root of a thread is the oldest message
+// of the chain that is still retained.
+func (s *MessageStore) GetThread(id uint64) ([]Message, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	root, ok := s.getLocked(id)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	for root.ReplyTo != 0 {
+		parent, ok := s.getLocked(root.ReplyTo)
+		if !ok {
+			break
+		}
+		root = parent
+	}
+
+	// replies always come after the message they answer, so one pass from the
+	// root collects the whole thread
+	members := map[uint64]bool{root.ID: true}
+	thread := []Message{root.redacted()}
+	for i := s.messages.search(root.ID + 1); i < s.messages.len(); i++ {
+		msg := s.shards.get(s.messages.at(i))
+		if msg.ReplyTo != 0 && members[msg.ReplyTo] {
+			members[msg.ID] = true
+			thread = append(thread, msg.redacted())
+		}
+	}
+	return thread, nil
+}
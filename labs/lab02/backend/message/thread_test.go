@@ -0,0 +1,37 @@
+package message
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestGetThread(t *testing.T) {
+	store := NewMessageStore()
+	root, _ := store.Append(Message{Sender: "alice", Content: "lunch?"})
+	other, _ := store.Append(Message{Sender: "carol", Content: "unrelated"})
+	reply, _ := store.Append(Message{Sender: "bob", Content: "sure", ReplyTo: root.ID})
+	store.Append(Message{Sender: "carol", Content: "+1", ReplyTo: other.ID})
+	nested, _ := store.Append(Message{Sender: "alice", Content: "noon?", ReplyTo: reply.ID})
+	store.DeleteMessage(reply.ID, "bob")
+
+	for _, id := range []uint64{root.ID, reply.ID, nested.ID} {
+		thread, err := store.GetThread(id)
+		if err != nil {
+			t.Fatalf("GetThread(%d) failed: %v", id, err)
+		}
+		if want := []uint64{root.ID, reply.ID, nested.ID}; !slices.Equal(ids(thread), want) {
+			t.Errorf("GetThread(%d): expected %v, got %v", id, want, ids(thread))
+		}
+		if thread[1].Content != "" {
+			t.Errorf("deleted reply should be redacted, got %q", thread[1].Content)
+		}
+	}
+
+	if _, err := store.GetThread(99); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected ErrMessageNotFound, got %v", err)
+	}
+	if _, err := store.Append(Message{Sender: "bob", Content: "?", ReplyTo: 99}); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("replies to unknown messages should fail, got %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+// Package redisbus implements chatcore.Bus on Redis pub/sub. It speaks the
+// RESP protocol directly and only needs PUBLISH, SUBSCRIBE and UNSUBSCRIBE.
+package redisbus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Publish once the bus is closed
+var ErrClosed = errors.New("redisbus: closed")
+
+// Timeouts of the bus
+const (
+	dialTimeout      = 5 * time.Second
+	subscribeTimeout = 5 * time.Second
+	maxBackoff       = 5 * time.Second
+)
+
+// Bus publishes on one connection and receives on another one that is in
+// subscribed mode. The subscribed connection is dialled again when it fails
+// and every topic subscribed to again.
+type Bus struct {
+	addr string
+
+	pubMutex sync.Mutex
+	pub      net.Conn
+	pubR     *bufio.Reader
+	pubW     *bufio.Writer
+
+	subMutex sync.Mutex
+	sub      net.Conn
+	subW     *bufio.Writer
+	handlers map[string]map[*handler]struct{} // topic -> handlers
+	acks     map[string][]chan struct{}       // "kind topic" -> callers waiting for the server
+	// reconnected are called once the topics are subscribed to again
+	reconnected []func()
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type handler struct {
+	fn func(payload []byte)
+}
+
+// Dial connects to the server at addr
+func Dial(ctx context.Context, addr string) (*Bus, error) {
+	b := &Bus{
+		addr:     addr,
+		handlers: make(map[string]map[*handler]struct{}),
+		acks:     make(map[string][]chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	sub, err := b.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.sub, b.subW = sub, bufio.NewWriter(sub)
+
+	b.wg.Add(1)
+	go b.readLoop(sub)
+	return b, nil
+}
+
+func (b *Bus) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	return dialer.DialContext(ctx, "tcp", b.addr)
+}
+
+// Publish sends payload to topic and returns the number of subscribed
+// connections that received it. A failed publish is not retried, the message
+// may or may not have reached the server.
+func (b *Bus) Publish(ctx context.Context, topic string, payload []byte) (int, error) {
+	select {
+	case <-b.closed:
+		return 0, ErrClosed
+	default:
+	}
+
+	b.pubMutex.Lock()
+	defer b.pubMutex.Unlock()
+
+	if b.pub == nil {
+		conn, err := b.dial(ctx)
+		if err != nil {
+			return 0, err
+		}
+		b.pub, b.pubR, b.pubW = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		b.pub.SetDeadline(deadline)
+	} else {
+		b.pub.SetDeadline(time.Time{})
+	}
+
+	reply, err := b.publish(topic, payload)
+	if err != nil {
+		b.pub.Close()
+		b.pub = nil
+		return 0, err
+	}
+	return reply, nil
+}
+
+func (b *Bus) publish(topic string, payload []byte) (int, error) {
+	if err := writeCommand(b.pubW, "PUBLISH", topic, string(payload)); err != nil {
+		return 0, err
+	}
+	value, err := readValue(b.pubR)
+	if err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case int64:
+		return int(v), nil
+	case respError:
+		return 0, v
+	}
+	return 0, errProtocol
+}
+
+// Subscribe calls fn with every payload published to topic, in order, from
+// the goroutine reading the subscribed connection. Both subscribing and
+// unsubscribing return once the server confirmed it or after a timeout, so a
+// publish counted by the server is handled.
+func (b *Bus) Subscribe(topic string, fn func(payload []byte)) (unsubscribe func()) {
+	h := &handler{fn: fn}
+
+	b.subMutex.Lock()
+	var ack chan struct{}
+	if b.handlers[topic] == nil {
+		b.handlers[topic] = make(map[*handler]struct{})
+		ack = b.expect("subscribe", topic)
+		b.command("SUBSCRIBE", topic)
+	}
+	b.handlers[topic][h] = struct{}{}
+	b.subMutex.Unlock()
+	b.await(ack)
+
+	return func() {
+		b.subMutex.Lock()
+		handlers := b.handlers[topic]
+		if _, ok := handlers[h]; !ok {
+			b.subMutex.Unlock()
+			return
+		}
+		var ack chan struct{}
+		if len(handlers) == 1 {
+			// keep handling messages until the server stops sending them
+			ack = b.expect("unsubscribe", topic)
+			b.command("UNSUBSCRIBE", topic)
+		}
+		b.subMutex.Unlock()
+		b.await(ack)
+
+		b.subMutex.Lock()
+		defer b.subMutex.Unlock()
+		delete(handlers, h)
+		if len(handlers) == 0 {
+			delete(b.handlers, topic)
+		}
+	}
+}
+
+// expect registers a wait for the confirmation of kind on topic. Called with
+// subMutex held.
+func (b *Bus) expect(kind, topic string) chan struct{} {
+	ack := make(chan struct{})
+	key := kind + " " + topic
+	b.acks[key] = append(b.acks[key], ack)
+	return ack
+}
+
+// await waits for a confirmation registered by expect, if any
+func (b *Bus) await(ack chan struct{}) {
+	if ack == nil {
+		return
+	}
+	select {
+	case <-ack:
+	case <-b.closed:
+	case <-time.After(subscribeTimeout):
+	}
+}
+
+// command writes to the subscribed connection, a failure is left to readLoop
+// which reconnects and subscribes again. Called with subMutex held.
+func (b *Bus) command(args ...string) {
+	if b.sub != nil {
+		b.sub.SetWriteDeadline(time.Now().Add(dialTimeout))
+		writeCommand(b.subW, args...)
+	}
+}
+
+// readLoop dispatches the messages of the subscribed connection and replaces
+// the connection when it fails
+func (b *Bus) readLoop(conn net.Conn) {
+	defer b.wg.Done()
+	backoff := 50 * time.Millisecond
+	for {
+		b.read(conn)
+
+		b.subMutex.Lock()
+		b.sub = nil
+		b.subMutex.Unlock()
+		// the publishing connection most likely broke along with it, the next
+		// publish dials again instead of failing once
+		b.pubMutex.Lock()
+		if b.pub != nil {
+			b.pub.Close()
+			b.pub = nil
+		}
+		b.pubMutex.Unlock()
+
+		for {
+			select {
+			case <-b.closed:
+				return
+			case <-time.After(backoff):
+			}
+			next, err := b.dial(context.Background())
+			if err == nil {
+				conn = next
+				backoff = 50 * time.Millisecond
+				break
+			}
+			backoff = min(2*backoff, maxBackoff)
+		}
+		if acks := b.resubscribe(conn); acks != nil {
+			b.wg.Add(1)
+			go b.notifyReconnected(acks)
+		}
+	}
+}
+
+// notifyReconnected calls the OnReconnect functions once the server confirmed
+// the subscriptions
+func (b *Bus) notifyReconnected(acks []chan struct{}) {
+	defer b.wg.Done()
+	for _, ack := range acks {
+		b.await(ack)
+	}
+	select {
+	case <-b.closed:
+		return
+	default:
+	}
+	b.subMutex.Lock()
+	fns := slices.Clone(b.reconnected)
+	b.subMutex.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// OnReconnect registers fn to be called after the subscribed connection was
+// replaced and every topic subscribed to again. Messages published while the
+// connection was down were lost, fn lets the subscriber catch up.
+func (b *Bus) OnReconnect(fn func()) {
+	b.subMutex.Lock()
+	defer b.subMutex.Unlock()
+	b.reconnected = append(b.reconnected, fn)
+}
+
+// read dispatches messages until the connection fails
+func (b *Bus) read(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		value, err := readValue(r)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		kind, topic, payload, ok := push(value)
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "message":
+			b.dispatch(topic, payload)
+		case "subscribe", "unsubscribe":
+			key := kind + " " + topic
+			b.subMutex.Lock()
+			for _, ack := range b.acks[key] {
+				close(ack)
+			}
+			delete(b.acks, key)
+			b.subMutex.Unlock()
+		}
+	}
+}
+
+func (b *Bus) dispatch(topic string, payload []byte) {
+	b.subMutex.Lock()
+	handlers := make([]*handler, 0, len(b.handlers[topic]))
+	for h := range b.handlers[topic] {
+		handlers = append(handlers, h)
+	}
+	b.subMutex.Unlock()
+
+	for _, h := range handlers {
+		h.fn(payload)
+	}
+}
+
+// resubscribe makes conn the subscribed connection and subscribes to every
+// topic again, it returns the confirmations to wait for or nil once closed
+func (b *Bus) resubscribe(conn net.Conn) []chan struct{} {
+	b.subMutex.Lock()
+	defer b.subMutex.Unlock()
+
+	select {
+	case <-b.closed:
+		conn.Close()
+		return nil
+	default:
+	}
+	b.sub, b.subW = conn, bufio.NewWriter(conn)
+	acks := []chan struct{}{}
+	if len(b.handlers) == 0 {
+		return acks
+	}
+	args := []string{"SUBSCRIBE"}
+	for topic := range b.handlers {
+		args = append(args, topic)
+		acks = append(acks, b.expect("subscribe", topic))
+	}
+	b.command(args...)
+	return acks
+}
+
+// Close closes both connections and stops the reader
+func (b *Bus) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closed)
+
+		b.subMutex.Lock()
+		if b.sub != nil {
+			b.sub.Close()
+		}
+		b.subMutex.Unlock()
+
+		b.pubMutex.Lock()
+		if b.pub != nil {
+			b.pub.Close()
+			b.pub = nil
+		}
+		b.pubMutex.Unlock()
+	})
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,207 @@
+package redisbus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"lab02/chatcore"
+)
+
+func dialBus(t *testing.T, addr string) *Bus {
+	t.Helper()
+	bus, err := Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+func receive(t *testing.T, ch chan string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("nothing received")
+		return ""
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	addr := serverAddr(t)
+	a, b := dialBus(t, addr), dialBus(t, addr)
+	ctx := context.Background()
+
+	got := make(chan string, 10)
+	unsubscribe := a.Subscribe("test.topic", func(payload []byte) { got <- string(payload) })
+	b.Subscribe("test.topic", func(payload []byte) { got <- "b:" + string(payload) })
+
+	n, err := a.Publish(ctx, "test.topic", []byte("line\r\nbreak"))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 receivers, got %d, %v", n, err)
+	}
+	received := map[string]bool{receive(t, got): true, receive(t, got): true}
+	if !received["line\r\nbreak"] || !received["b:line\r\nbreak"] {
+		t.Errorf("payload was not kept intact: %v", received)
+	}
+
+	unsubscribe()
+	if n, _ := b.Publish(ctx, "test.topic", []byte("x")); n != 1 {
+		t.Errorf("expected 1 receiver after unsubscribing, got %d", n)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	addr := serverAddr(t)
+	a, b := dialBus(t, addr), dialBus(t, addr)
+
+	got := make(chan string, 100)
+	a.Subscribe("test.order", func(payload []byte) { got <- string(payload) })
+	for i := 0; i < 100; i++ {
+		b.Publish(context.Background(), "test.order", []byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 100; i++ {
+		if s := receive(t, got); s != fmt.Sprint(i) {
+			t.Fatalf("expected %d, got %s", i, s)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server := startStandin(t)
+	addr := server.listener.Addr().String()
+	a, b := dialBus(t, addr), dialBus(t, addr)
+
+	got := make(chan string, 10)
+	a.Subscribe("test.reconnect", func(payload []byte) { got <- string(payload) })
+	server.dropClients()
+
+	// publishing fails once the connection is gone, then the subscription comes back
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, _ := b.Publish(context.Background(), "test.reconnect", []byte("back"))
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the subscription was not restored")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if s := receive(t, got); s != "back" {
+		t.Errorf("unexpected payload %q", s)
+	}
+}
+
+func TestBrokersExactlyOnce(t *testing.T) {
+	addr := serverAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes := make([]*chatcore.Broker, 2)
+	for i := range nodes {
+		nodes[i] = chatcore.NewBroker(ctx, chatcore.WithBus(dialBus(t, addr), fmt.Sprint("node", i)))
+		go nodes[i].Run()
+	}
+	alice, bob := make(chan chatcore.Message, 10), make(chan chatcore.Message, 10)
+	nodes[0].RegisterUser("alice", alice)
+	nodes[1].RegisterUser("bob", bob)
+
+	nodes[0].SendMessage(chatcore.Message{Sender: "alice", Recipient: "bob", Content: "direct"})
+	nodes[1].SendMessage(chatcore.Message{Sender: "bob", Content: "all", Broadcast: true})
+
+	expect := func(name string, ch chan chatcore.Message, contents ...string) {
+		t.Helper()
+		for _, want := range contents {
+			select {
+			case m := <-ch:
+				if m.Content != want {
+					t.Errorf("%s: expected %q, got %q", name, want, m.Content)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: did not receive %q", name, want)
+			}
+		}
+		select {
+		case m := <-ch:
+			t.Errorf("%s: unexpected extra message %+v", name, m)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	expect("alice", alice, "all")
+	expect("bob", bob, "direct", "all")
+
+	// alice moving to node1 closes her registration on node0
+	moved := make(chan chatcore.Message, 10)
+	nodes[1].RegisterUser("alice", moved)
+	select {
+	case _, ok := <-alice:
+		if ok {
+			t.Error("expected the old channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("node0 did not drop alice")
+	}
+	nodes[0].SendMessage(chatcore.Message{Sender: "bob", Recipient: "alice", Content: "moved"})
+	expect("alice", moved, "moved")
+}
+
+func TestBrokersCatchUpAfterReconnect(t *testing.T) {
+	server := startStandin(t)
+	addr := server.listener.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes := make([]*chatcore.Broker, 2)
+	for i := range nodes {
+		nodes[i] = chatcore.NewBroker(ctx, chatcore.WithBus(dialBus(t, addr), fmt.Sprint("node", i)))
+		go nodes[i].Run()
+	}
+	bob := make(chan chatcore.Message, 10)
+	nodes[1].RegisterUser("bob", bob)
+	server.dropClients()
+
+	// node1 is not subscribed yet, so node0 keeps the message for bob
+	deadline := time.Now().Add(2 * time.Second)
+	for nodes[0].SendMessage(chatcore.Message{Sender: "alice", Recipient: "bob", Content: "meanwhile"}) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("node0 did not reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case m := <-bob:
+		if m.Content != "meanwhile" {
+			t.Errorf("unexpected message %+v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the message sent while node1 was reconnecting never arrived")
+	}
+	if n := nodes[0].Pending("bob"); n != 0 {
+		t.Errorf("expected no message left on node0, got %d", n)
+	}
+}
+
+func TestBrokersUniqueIDs(t *testing.T) {
+	addr := serverAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids := make(map[uint64]bool)
+	for i := range 2 {
+		node := chatcore.NewBroker(ctx, chatcore.WithBus(dialBus(t, addr), fmt.Sprint("node", i)))
+		for range 3 {
+			msg, err := node.Send(chatcore.Message{Sender: "alice", Recipient: "bob", Content: "hi"})
+			if err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			if ids[msg.ID] {
+				t.Errorf("node%d reused message ID %d", i, msg.ID)
+			}
+			ids[msg.ID] = true
+		}
+	}
+}
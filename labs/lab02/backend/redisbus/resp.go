@@ -0,0 +1,91 @@
+package redisbus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// respError is an error reply of the server
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+var errProtocol = errors.New("redisbus: protocol error")
+
+// writeCommand writes args as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args ...string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return w.Flush()
+}
+
+// readValue reads one RESP value: a string for simple strings, respError,
+// int64, []byte or nil for bulk strings and []any or nil for arrays
+func readValue(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return respError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = readValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, errProtocol
+}
+
+// push decodes a message of a subscribed connection, ["message", topic,
+// payload] or a (un)subscribe confirmation
+func push(value any) (kind, topic string, payload []byte, ok bool) {
+	values, isArray := value.([]any)
+	if !isArray || len(values) != 3 {
+		return "", "", nil, false
+	}
+	k, _ := values[0].([]byte)
+	t, _ := values[1].([]byte)
+	p, _ := values[2].([]byte)
+	return string(k), string(t), p, true
+}
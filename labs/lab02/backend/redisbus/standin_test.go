@@ -0,0 +1,173 @@
+package redisbus
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// standin is a local server implementing the pub/sub commands of Redis, tests
+// run against it unless REDIS_ADDR points at a real server
+type standin struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	clients  map[*standinClient]struct{}
+	subs     map[string]map[*standinClient]struct{}
+}
+
+type standinClient struct {
+	conn   net.Conn
+	mutex  sync.Mutex
+	w      *bufio.Writer
+	topics map[string]struct{}
+}
+
+// serverAddr returns the address of the server tests use
+func serverAddr(t *testing.T) string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return startStandin(t).listener.Addr().String()
+}
+
+func startStandin(t *testing.T) *standin {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &standin{
+		listener: listener,
+		clients:  make(map[*standinClient]struct{}),
+		subs:     make(map[string]map[*standinClient]struct{}),
+	}
+	go s.accept()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropClients()
+	})
+	return s
+}
+
+func (s *standin) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &standinClient{conn: conn, w: bufio.NewWriter(conn), topics: make(map[string]struct{})}
+		s.mutex.Lock()
+		s.clients[c] = struct{}{}
+		s.mutex.Unlock()
+		go s.serve(c)
+	}
+}
+
+// dropClients closes every connection, as a server restart would
+func (s *standin) dropClients() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+}
+
+func (s *standin) serve(c *standinClient) {
+	defer s.remove(c)
+	r := bufio.NewReader(c.conn)
+	for {
+		value, err := readValue(r)
+		if err != nil {
+			return
+		}
+		values, _ := value.([]any)
+		args := make([]string, len(values))
+		for i, v := range values {
+			data, _ := v.([]byte)
+			args[i] = string(data)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			c.send("+PONG\r\n")
+		case "PUBLISH":
+			if len(args) != 3 {
+				c.send("-ERR wrong number of arguments\r\n")
+				continue
+			}
+			c.send(":" + itoa(s.publish(args[1], args[2])) + "\r\n")
+		case "SUBSCRIBE", "UNSUBSCRIBE":
+			kind := strings.ToLower(args[0])
+			for _, topic := range args[1:] {
+				n := s.subscribe(c, topic, kind == "subscribe")
+				c.send("*3\r\n" + bulk(kind) + bulk(topic) + ":" + itoa(n) + "\r\n")
+			}
+		default:
+			c.send("-ERR unknown command\r\n")
+		}
+	}
+}
+
+func (s *standin) publish(topic, payload string) int {
+	s.mutex.Lock()
+	subs := make([]*standinClient, 0, len(s.subs[topic]))
+	for c := range s.subs[topic] {
+		subs = append(subs, c)
+	}
+	s.mutex.Unlock()
+
+	for _, c := range subs {
+		c.send("*3\r\n" + bulk("message") + bulk(topic) + bulk(payload))
+	}
+	return len(subs)
+}
+
+// subscribe adds or removes c from the subscribers of topic and returns the
+// number of topics c is subscribed to
+func (s *standin) subscribe(c *standinClient, topic string, add bool) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if add {
+		if s.subs[topic] == nil {
+			s.subs[topic] = make(map[*standinClient]struct{})
+		}
+		s.subs[topic][c] = struct{}{}
+		c.topics[topic] = struct{}{}
+	} else {
+		delete(s.subs[topic], c)
+		delete(c.topics, topic)
+	}
+	return len(c.topics)
+}
+
+func (s *standin) remove(c *standinClient) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for topic := range c.topics {
+		delete(s.subs[topic], c)
+	}
+	delete(s.clients, c)
+	c.conn.Close()
+}
+
+func (c *standinClient) send(reply string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.w.WriteString(reply)
+	c.w.Flush()
+}
+
+func bulk(s string) string {
+	return "$" + itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,68 @@
+package user
+
+import "slices"
+
+// Block adds blockedID to the block list of a user, blocking twice is a no-op
+func (m *UserManager) Block(userID, blockedID string) error {
+	if blockedID == "" || blockedID == userID {
+		return ErrInvalidBlock
+	}
+	return m.updateBlocked(userID, func(blocked []string) []string {
+		if i, found := slices.BinarySearch(blocked, blockedID); !found {
+			blocked = slices.Insert(blocked, i, blockedID)
+		}
+		return blocked
+	})
+}
+
+// Unblock removes blockedID from the block list of a user
+func (m *UserManager) Unblock(userID, blockedID string) error {
+	return m.updateBlocked(userID, func(blocked []string) []string {
+		if i, found := slices.BinarySearch(blocked, blockedID); found {
+			blocked = slices.Delete(blocked, i, i+1)
+		}
+		return blocked
+	})
+}
+
+// updateBlocked persists the block list fn makes of a copy of the user's one,
+// if it changed
+func (m *UserManager) updateBlocked(userID string, fn func(blocked []string) []string) error {
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	blocked := fn(slices.Clone(u.Blocked))
+	if slices.Equal(blocked, u.Blocked) {
+		return nil
+	}
+	if len(blocked) == 0 {
+		blocked = nil
+	}
+	u.Blocked = blocked
+	return m.putLocked(u)
+}
+
+// Blocked returns the IDs of the users a user blocked, sorted
+func (m *UserManager) Blocked(userID string) ([]string, error) {
+	u, err := m.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.Blocked, nil
+}
+
+// IsBlocked reports whether userID blocked senderID, unknown users block nobody
+func (m *UserManager) IsBlocked(userID, senderID string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, found := slices.BinarySearch(m.users[userID].Blocked, senderID)
+	return found
+}
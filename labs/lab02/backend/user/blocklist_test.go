@@ -0,0 +1,61 @@
+package user
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBlockList(t *testing.T) {
+	mgr := NewUserManager()
+	mgr.AddUser(User{Name: "Alice", Email: "alice@example.com", ID: "alice"})
+
+	tests := []struct {
+		name    string
+		block   bool
+		user    string
+		other   string
+		wantErr error
+		want    []string
+	}{
+		{"block", true, "alice", "mallory", nil, []string{"mallory"}},
+		{"block again", true, "alice", "mallory", nil, []string{"mallory"}},
+		{"block another", true, "alice", "eve", nil, []string{"eve", "mallory"}},
+		{"block self", true, "alice", "alice", ErrInvalidBlock, []string{"eve", "mallory"}},
+		{"block nobody", true, "alice", "", ErrInvalidBlock, []string{"eve", "mallory"}},
+		{"unknown user", true, "bob", "eve", ErrUserNotFound, []string{"eve", "mallory"}},
+		{"unblock", false, "alice", "eve", nil, []string{"mallory"}},
+		{"unblock not blocked", false, "alice", "eve", nil, []string{"mallory"}},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.block {
+			err = mgr.Block(tt.user, tt.other)
+		} else {
+			err = mgr.Unblock(tt.user, tt.other)
+		}
+		if err != tt.wantErr {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if blocked, _ := mgr.Blocked("alice"); !slices.Equal(blocked, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, blocked)
+		}
+	}
+
+	if !mgr.IsBlocked("alice", "mallory") || mgr.IsBlocked("alice", "eve") || mgr.IsBlocked("mallory", "alice") {
+		t.Error("IsBlocked does not match the block list")
+	}
+}
+
+func TestBlockListIsCopied(t *testing.T) {
+	mgr := NewUserManager()
+	mgr.AddUser(User{Name: "Alice", Email: "alice@example.com", ID: "alice", Blocked: []string{"mallory", "eve", "eve"}})
+
+	u, _ := mgr.GetUser("alice")
+	if !slices.Equal(u.Blocked, []string{"eve", "mallory"}) {
+		t.Fatalf("expected a sorted block list without duplicates, got %v", u.Blocked)
+	}
+	u.Blocked[0] = "bob"
+	if mgr.IsBlocked("alice", "bob") {
+		t.Error("changing a returned user must not change the block list")
+	}
+}
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+
+	"lab02/internal/jsonlog"
+)
+
+// ErrCorruptLog is returned when a log record other than the last one cannot be decoded
+var ErrCorruptLog = errors.New("corrupt user log")
+
+// Record is a change to the users of a UserManager, either the new version of
+// a user, block list included, or the ID of a removed user
+type Record struct {
+	User    *User  `json:"user,omitempty"`
+	Removed string `json:"removed,omitempty"`
+}
+
+// Backend durably stores the users of a UserManager
+type Backend interface {
+	// Append persists rec, once it returns nil the change survives a restart
+	Append(rec Record) error
+	// Replay calls fn for every stored record in the order they were appended
+	Replay(fn func(Record) error) error
+	Close() error
+}
+
+// FileLog is a Backend that appends records to a file as JSON lines and syncs
+// the file after every record
+type FileLog struct {
+	*jsonlog.Log[Record]
+}
+
+// OpenFileLog opens the log at path, creating it if needed
+func OpenFileLog(path string) (*FileLog, error) {
+	log, err := jsonlog.Open[Record](path, ErrCorruptLog)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLog{log}, nil
+}
+
+// Replay reads the log from the start, a record that is neither a user nor a
+// removal is corrupt
+func (l *FileLog) Replay(fn func(Record) error) error {
+	return l.Log.Replay(func(rec Record) error {
+		if rec.User == nil && rec.Removed == "" {
+			return fmt.Errorf("%w: empty record", ErrCorruptLog)
+		}
+		return fn(rec)
+	})
+}
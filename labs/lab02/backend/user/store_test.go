@@ -0,0 +1,114 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	log, err := OpenFileLog(path)
+	if err != nil {
+		t.Fatalf("OpenFileLog failed: %v", err)
+	}
+	mgr, err := NewUserManagerWithBackend(context.Background(), log)
+	if err != nil {
+		t.Fatalf("NewUserManagerWithBackend failed: %v", err)
+	}
+	mgr.AddUser(User{Name: "Alice", Email: "alice@example.com", ID: "alice"})
+	mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"})
+	mgr.Block("alice", "bob")
+	mgr.Block("alice", "eve")
+	mgr.Unblock("alice", "eve")
+	mgr.RemoveUser("bob")
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	log, _ = OpenFileLog(path)
+	mgr, err = NewUserManagerWithBackend(context.Background(), log)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	defer mgr.Close()
+
+	if _, err := mgr.GetUser("bob"); err != ErrUserNotFound {
+		t.Errorf("expected bob to stay removed, got %v", err)
+	}
+	alice, err := mgr.GetUser("alice")
+	if err != nil || alice.Name != "Alice" || !slices.Equal(alice.Blocked, []string{"bob"}) {
+		t.Errorf("unexpected replayed user %+v, %v", alice, err)
+	}
+}
+
+func TestFileLogTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	data := `{"user":{"name":"Alice","email":"alice@example.com","id":"alice"}}` + "\n" + `{"user":{"name":"Bo`
+	os.WriteFile(path, []byte(data), 0o644)
+
+	log, _ := OpenFileLog(path)
+	mgr, err := NewUserManagerWithBackend(context.Background(), log)
+	if err != nil {
+		t.Fatalf("a torn last record should be dropped: %v", err)
+	}
+	mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"})
+	mgr.Close()
+
+	log, _ = OpenFileLog(path)
+	mgr, err = NewUserManagerWithBackend(context.Background(), log)
+	if err != nil {
+		t.Fatalf("replay after the torn write failed: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.GetUser("bob"); err != nil {
+		t.Errorf("expected the user added after the torn write: %v", err)
+	}
+
+	os.WriteFile(path, []byte("garbage\n"), 0o644)
+	log, _ = OpenFileLog(path)
+	defer log.Close()
+	if _, err := NewUserManagerWithBackend(context.Background(), log); !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("expected ErrCorruptLog, got %v", err)
+	}
+}
+
+func TestChangesAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	log, _ := OpenFileLog(path)
+	mgr, _ := NewUserManagerWithBackend(context.Background(), log)
+	mgr.AddUser(User{Name: "Alice", Email: "alice@example.com", ID: "alice"})
+	mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"})
+	mgr.Block("alice", "eve")
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{"add", func() error { return mgr.AddUser(User{Name: "Carol", Email: "carol@example.com", ID: "carol"}) }},
+		{"remove", func() error { return mgr.RemoveUser("bob") }},
+		{"block", func() error { return mgr.Block("alice", "bob") }},
+		{"unblock", func() error { return mgr.Unblock("alice", "eve") }},
+	}
+	for _, tt := range changes {
+		if err := tt.change(); err != ErrClosed {
+			t.Errorf("%s: expected ErrClosed, got %v", tt.name, err)
+		}
+	}
+	if u, err := mgr.GetUser("bob"); err != nil || u.ID != "bob" {
+		t.Errorf("expected reads to keep working, got %+v, %v", u, err)
+	}
+
+	log, _ = OpenFileLog(path)
+	mgr, _ = NewUserManagerWithBackend(context.Background(), log)
+	defer mgr.Close()
+	if _, err := mgr.GetUser("carol"); err != ErrUserNotFound {
+		t.Errorf("expected no change to be persisted after Close, got %v", err)
+	}
+}
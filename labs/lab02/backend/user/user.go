@@ -3,65 +3,181 @@ package user
 import (
 	"context"
 	"errors"
+	"net/mail"
+	"slices"
+	"strings"
 	"sync"
 )
 
+// Errors returned by User and UserManager
+var (
+	ErrEmptyName    = errors.New("name cannot be empty")
+	ErrInvalidEmail = errors.New("invalid email")
+	ErrEmptyID      = errors.New("id cannot be empty")
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+	ErrInvalidBlock = errors.New("invalid user to block")
+	ErrClosed       = errors.New("user manager closed")
+)
+
 // User represents a chat user
-// TODO: Add more fields if needed
 
 type User struct {
-	Name  string
-	Email string
-	ID    string
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	ID    string `json:"id"`
+	// Blocked holds the IDs of the users this user blocked, sorted
+	Blocked []string `json:"blocked,omitempty"`
 }
 
 // Validate checks if the user data is valid
 func (u *User) Validate() error {
-	// TODO: Validate name, email, id
+	if strings.TrimSpace(u.Name) == "" {
+		return ErrEmptyName
+	}
+	if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+		return ErrInvalidEmail
+	}
+	if strings.TrimSpace(u.ID) == "" {
+		return ErrEmptyID
+	}
 	return nil
 }
 
+// clone returns a copy of the user that shares no memory with the original
+func (u User) clone() User {
+	u.Blocked = slices.Clone(u.Blocked)
+	return u
+}
+
 // UserManager manages users
 // Contains a map of users, a mutex, and a context
 
 type UserManager struct {
-	ctx   context.Context
-	users map[string]User // userID -> User
-	mutex sync.RWMutex    // Protects users map
-	// TODO: Add more fields if needed
+	ctx     context.Context
+	users   map[string]User // userID -> User
+	mutex   sync.RWMutex    // Protects users map
+	backend Backend
+	closed  bool
 }
 
 // NewUserManager creates a new UserManager
 func NewUserManager() *UserManager {
-	// TODO: Initialize UserManager fields
-	return &UserManager{
-		users: make(map[string]User),
-	}
+	return NewUserManagerWithContext(context.Background())
 }
 
-// NewUserManagerWithContext creates a new UserManager with context
+// NewUserManagerWithContext creates a new UserManager with context, changes
+// fail once the context is cancelled
 func NewUserManagerWithContext(ctx context.Context) *UserManager {
-	// TODO: Initialize UserManager with context
 	return &UserManager{
 		ctx:   ctx,
 		users: make(map[string]User),
 	}
 }
 
+// NewUserManagerWithBackend creates a UserManager that persists every change
+// to backend, replaying the users the backend already holds
+func NewUserManagerWithBackend(ctx context.Context, backend Backend) (*UserManager, error) {
+	m := NewUserManagerWithContext(ctx)
+	err := backend.Replay(func(rec Record) error {
+		if rec.User != nil {
+			m.users[rec.User.ID] = *rec.User
+		} else {
+			delete(m.users, rec.Removed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.backend = backend
+	return m, nil
+}
+
 // AddUser adds a user
 func (m *UserManager) AddUser(u User) error {
-	// TODO: Add user to map, check context
-	return nil
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	u = u.clone()
+	slices.Sort(u.Blocked)
+	u.Blocked = slices.Compact(u.Blocked)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.users[u.ID]; ok {
+		return ErrUserExists
+	}
+	return m.putLocked(u)
 }
 
 // RemoveUser removes a user
 func (m *UserManager) RemoveUser(id string) error {
-	// TODO: Remove user from map
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	if err := m.persistLocked(Record{Removed: id}); err != nil {
+		return err
+	}
+	delete(m.users, id)
 	return nil
 }
 
 // GetUser retrieves a user by id
 func (m *UserManager) GetUser(id string) (User, error) {
-	// TODO: Get user from map
-	return User{}, errors.New("not found")
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return u.clone(), nil
+}
+
+// putLocked persists a new version of a user, then stores it
+func (m *UserManager) putLocked(u User) error {
+	if err := m.persistLocked(Record{User: &u}); err != nil {
+		return err
+	}
+	m.users[u.ID] = u
+	return nil
+}
+
+// persistLocked writes a change to the backend, if any. Once the manager is
+// closed every change fails with ErrClosed.
+func (m *UserManager) persistLocked(rec Record) error {
+	if m.closed {
+		return ErrClosed
+	}
+	if m.backend == nil {
+		return nil
+	}
+	return m.backend.Append(rec)
+}
+
+// Close closes the backend of the manager, if any. Users can still be read,
+// changes return ErrClosed.
+func (m *UserManager) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if m.backend == nil {
+		return nil
+	}
+	return m.backend.Close()
 }
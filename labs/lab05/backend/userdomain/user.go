@@ -1,11 +1,15 @@
 package userdomain
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	_ "regexp"
 	"strings"
 	"time"
+
+	"shared/emailaddr"
+	"shared/personname"
 )
 
 // User represents a user entity in the domain
@@ -31,7 +35,8 @@ func NewUser(email, name, password string) (*User, error) {
 	if err := ValidateEmail(email); err != nil {
 		return nil, err
 	}
-	if err := ValidateName(name); err != nil {
+	name, err := normalizeName(name)
+	if err != nil {
 		return nil, err
 	}
 	if err := ValidatePassword(password); err != nil {
@@ -39,7 +44,7 @@ func NewUser(email, name, password string) (*User, error) {
 	}
 	user := &User{
 		Email:     strings.ToLower(strings.TrimSpace(email)),
-		Name:      strings.TrimSpace(name),
+		Name:      name,
 		Password:  password,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -64,36 +69,44 @@ func (u *User) Validate() error {
 	return nil
 }
 
-func ValidateEmail(email string) error {
+// emailPolicy holds the validator set with SetEmailValidator
+var emailPolicy emailaddr.Setting
 
+// SetEmailValidator makes ValidateEmail apply the domain policies of v, such
+// as MX checks or blocklists, nil restores the plain syntax check
+func SetEmailValidator(v *emailaddr.Validator) {
+	emailPolicy.Set(v)
+}
+
+// ValidateEmail checks the trimmed, lower-cased email against RFC 5322 and the
+// configured domain policies, errors are those of package emailaddr
+func ValidateEmail(email string) error {
 	email = strings.ToLower(strings.TrimSpace(email))
-	if email == "" {
-		return errors.New("email cannot be empty")
-	}
-	if !regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`).MatchString(email) {
-		return errors.New("invalid email format")
-	}
-	return nil
+	return emailPolicy.Validate(context.Background(), email)
+}
+
+// defaultNamePolicy are the name limits used unless SetNamePolicy changes them
+var defaultNamePolicy = personname.Policy{MinLength: 2, MaxLength: 51}
+
+// namePolicy holds the limits set with SetNamePolicy
+var namePolicy = personname.NewSetting(defaultNamePolicy)
+
+// SetNamePolicy changes the length limits ValidateName applies, counted in
+// characters as a reader perceives them
+func SetNamePolicy(p personname.Policy) {
+	namePolicy.Set(p)
+}
+
+// normalizeName trims name, converts it to NFC and validates it against the name policy
+func normalizeName(name string) (string, error) {
+	return namePolicy.Policy().Normalize(name)
 }
 
+// ValidateName checks that the trimmed name is 2-51 characters long, free of
+// control and invisible characters and not mixing letters of unrelated scripts
 func ValidateName(name string) error {
-	// Name should be 2-51 characters, trimmed of whitespace
-	trimmed := strings.TrimSpace(name)
-	length := len(trimmed)
-	if length < 2 || length > 51 {
-		return errors.New("name must be between 2 and 51 characters")
-	}
-	// Name must contain at least 2 printable, non-whitespace characters
-	count := 0
-	for _, r := range trimmed {
-		if r > 32 && r != 127 { // printable, not control or DEL
-			count++
-		}
-	}
-	if count < 2 {
-		return errors.New("name must contain at least 2 visible characters")
-	}
-	return nil
+	_, err := normalizeName(name)
+	return err
 }
 
 func ValidatePassword(password string) error {
@@ -112,10 +125,11 @@ func ValidatePassword(password string) error {
 
 // UpdateName updates the user's name with validation
 func (u *User) UpdateName(name string) error {
-	if err := ValidateName(name); err != nil {
+	name, err := normalizeName(name)
+	if err != nil {
 		return err
 	}
-	u.Name = strings.TrimSpace(name)
+	u.Name = name
 	u.UpdatedAt = time.Now()
 	return nil
 }
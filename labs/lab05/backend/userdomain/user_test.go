@@ -1,11 +1,17 @@
 package userdomain
 
 import (
+	"context"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"shared/emailaddr"
+	"shared/personname"
 )
 
 func TestNewUser(t *testing.T) {
@@ -125,12 +131,18 @@ func TestValidateName(t *testing.T) {
 	}{
 		{"valid name", "John Doe", false},
 		{"minimum length name", "Jo", false},
-		{"maximum length name", "John" + string(make([]byte, 46)), false}, // 50 chars total
-		{"name with spaces", "  John Doe  ", false},                       // Should be trimmed
+		{"maximum length name", "John" + strings.Repeat("n", 47), false}, // 51 chars total
+		{"name with spaces", "  John Doe  ", false},                      // Should be trimmed
+		{"cyrillic name", "Александра Константиновна Петрова", false},
+		{"cyrillic maximum length name", strings.Repeat("Ж", 51), false},
+		{"combining accents", "Jose\u0301 Mari\u0301a", false},
 		{"empty name", "", true},
 		{"name too short", "J", true},
-		{"name too long", string(make([]byte, 51)), true},
+		{"name too long", strings.Repeat("n", 52), true},
 		{"only spaces", "   ", true},
+		{"control characters", "John" + string(make([]byte, 46)), true},
+		{"zero-width space", "John\u200bDoe", true},
+		{"mixed scripts", "P\u0430ypal", true},
 	}
 
 	for _, tt := range tests {
@@ -283,3 +295,51 @@ func TestUser_UpdateEmail(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "test@example.com", user.Email)
 }
+
+type stubResolver struct {
+	mx map[string][]*net.MX
+}
+
+func (r stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestValidateEmail_Policies(t *testing.T) {
+	assert.NoError(t, ValidateEmail(`"john doe"@example.com`))
+	assert.NoError(t, ValidateEmail("  User@Bücher.Example  "))
+	assert.ErrorIs(t, ValidateEmail("john@notvalid"), emailaddr.ErrInvalidDomain)
+
+	SetEmailValidator(emailaddr.NewValidator(
+		emailaddr.WithDisposableBlocklist(),
+		emailaddr.WithResolver(stubResolver{mx: map[string][]*net.MX{"example.com": {{Host: "mx.example.com."}}}}),
+	))
+	defer SetEmailValidator(nil)
+
+	assert.NoError(t, ValidateEmail("test@EXAMPLE.com"))
+	assert.ErrorIs(t, ValidateEmail("test@yopmail.com"), emailaddr.ErrDisposable)
+	assert.ErrorIs(t, ValidateEmail("test@no-mail.example"), emailaddr.ErrNoMailExchange)
+
+	_, err := NewUser("test@no-mail.example", "John Doe", "Password123")
+	assert.ErrorIs(t, err, emailaddr.ErrNoMailExchange)
+}
+
+func TestNameNormalization(t *testing.T) {
+	user, err := NewUser("test@example.com", "  Jose\u0301  ", "Password123")
+	require.NoError(t, err)
+	assert.Equal(t, "Jos\u00e9", user.Name)
+
+	require.NoError(t, user.UpdateName("Rene\u0301e"))
+	assert.Equal(t, "Ren\u00e9e", user.Name)
+
+	SetNamePolicy(personname.Policy{MinLength: 2, MaxLength: 4})
+	defer SetNamePolicy(defaultNamePolicy)
+	assert.ErrorIs(t, ValidateName("Johnny"), personname.ErrTooLong)
+	assert.NoError(t, ValidateName("Jo"))
+}
@@ -0,0 +1,33 @@
+package emailaddr
+
+// disposableDomains lists well-known disposable email services, subdomains
+// of these are blocked as well
+var disposableDomains = map[string]bool{
+	"10minutemail.com":       true,
+	"33mail.com":             true,
+	"dispostable.com":        true,
+	"emailondeck.com":        true,
+	"fakeinbox.com":          true,
+	"getairmail.com":         true,
+	"getnada.com":            true,
+	"guerrillamail.biz":      true,
+	"guerrillamail.com":      true,
+	"guerrillamail.de":       true,
+	"guerrillamail.net":      true,
+	"guerrillamail.org":      true,
+	"guerrillamailblock.com": true,
+	"mailcatch.com":          true,
+	"maildrop.cc":            true,
+	"mailinator.com":         true,
+	"mailnesia.com":          true,
+	"mintemail.com":          true,
+	"mohmal.com":             true,
+	"sharklasers.com":        true,
+	"spamgourmet.com":        true,
+	"temp-mail.org":          true,
+	"tempmail.com":           true,
+	"tempmailo.com":          true,
+	"throwawaymail.com":      true,
+	"trashmail.com":          true,
+	"yopmail.com":            true,
+}
@@ -0,0 +1,346 @@
+// Package emailaddr validates email addresses following the addr-spec grammar
+// of RFC 5322 and the length limits of RFC 5321. Domains may be written in
+// Unicode and are converted to their punycode form. On top of the syntax
+// check a Validator can reject disposable domains and require the domain to
+// accept mail, using a Resolver that can be stubbed in tests.
+package emailaddr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// Validation errors
+var (
+	ErrEmpty          = errors.New("email cannot be empty")
+	ErrInvalidFormat  = errors.New("invalid email format")
+	ErrTooLong        = errors.New("email address longer than 254 characters")
+	ErrLocalTooLong   = errors.New("email local part longer than 64 characters")
+	ErrInvalidDomain  = errors.New("invalid email domain")
+	ErrDisposable     = errors.New("disposable email domains are not allowed")
+	ErrBlockedDomain  = errors.New("email domain is not allowed")
+	ErrNoMailExchange = errors.New("email domain does not accept mail")
+)
+
+// Length limits from RFC 5321 section 4.5.3.1
+const (
+	MaxLength       = 254
+	MaxLocalLength  = 64
+	MaxDomainLength = 253
+	MaxLabelLength  = 63
+)
+
+// Address is a syntactically valid email address
+type Address struct {
+	// Local is the part before the @, quoted local parts keep their quotes
+	Local string
+	// Domain is the lower-case ASCII form of the domain, IDN labels are
+	// punycode encoded and address literals keep their brackets
+	Domain string
+}
+
+// String returns the address in its canonical form
+func (a Address) String() string {
+	return a.Local + "@" + a.Domain
+}
+
+// IsLiteral reports whether the domain is an address literal such as [192.0.2.1]
+func (a Address) IsLiteral() bool {
+	return strings.HasPrefix(a.Domain, "[")
+}
+
+// Parse checks the syntax and length of email and returns its parts
+func Parse(email string) (Address, error) {
+	if email == "" {
+		return Address{}, ErrEmpty
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return Address{}, ErrInvalidFormat
+	}
+
+	local, domain := email[:at], email[at+1:]
+	if !isDotAtom(local) && !isQuotedString(local) {
+		return Address{}, ErrInvalidFormat
+	}
+	if len(local) > MaxLocalLength {
+		return Address{}, ErrLocalTooLong
+	}
+
+	var err error
+	if strings.HasPrefix(domain, "[") {
+		domain, err = parseLiteral(domain)
+	} else {
+		domain, err = parseDomain(domain)
+	}
+	if err != nil {
+		return Address{}, err
+	}
+
+	addr := Address{Local: local, Domain: domain}
+	if len(addr.String()) > MaxLength {
+		return Address{}, ErrTooLong
+	}
+	return addr, nil
+}
+
+// Validate checks the syntax of email, see Parse
+func Validate(email string) error {
+	_, err := Parse(email)
+	return err
+}
+
+// isAtext reports whether c may appear unquoted in a local part
+func isAtext(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isDotAtom reports whether s is a dot-atom, atext runs separated by single dots
+func isDotAtom(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' || strings.Contains(s, "..") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '.' && !isAtext(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isQuotedString reports whether s is a quoted-string of printable ASCII,
+// where backslash escapes the next character
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+			if i == len(s)-1 || !isPrintable(s[i]) {
+				return false
+			}
+		case c == '"' || !isPrintable(c):
+			return false
+		}
+	}
+	return true
+}
+
+func isPrintable(c byte) bool {
+	return c == '\t' || (c >= ' ' && c <= '~')
+}
+
+// parseDomain converts domain to lower-case ASCII and checks it is a host
+// name with at least two labels and a non-numeric top-level domain
+func parseDomain(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDomain, err)
+	}
+	if len(ascii) > MaxDomainLength {
+		return "", ErrInvalidDomain
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > MaxLabelLength {
+			return "", ErrInvalidDomain
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", ErrInvalidDomain
+	}
+	return ascii, nil
+}
+
+// parseLiteral checks an address literal, [IPv4] or [IPv6:address]
+func parseLiteral(domain string) (string, error) {
+	if !strings.HasSuffix(domain, "]") {
+		return "", ErrInvalidFormat
+	}
+	inner := domain[1 : len(domain)-1]
+	if v6, ok := strings.CutPrefix(inner, "IPv6:"); ok {
+		if ip := net.ParseIP(v6); ip == nil || !strings.Contains(v6, ":") {
+			return "", ErrInvalidDomain
+		}
+		return domain, nil
+	}
+	if ip := net.ParseIP(inner); ip == nil || ip.To4() == nil || strings.Contains(inner, ":") {
+		return "", ErrInvalidDomain
+	}
+	return domain, nil
+}
+
+// Resolver looks up DNS records, *net.Resolver implements it
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Validator applies domain policies on top of the syntax check, the zero
+// value only checks syntax
+type Validator struct {
+	resolver   Resolver
+	timeout    time.Duration
+	blocked    map[string]bool
+	disposable bool
+}
+
+// Option configures a Validator
+type Option func(*Validator)
+
+// WithResolver makes the validator require that the domain accepts mail,
+// through MX records or, failing those, an address record as RFC 5321 allows
+func WithResolver(r Resolver) Option {
+	return func(v *Validator) {
+		v.resolver = r
+	}
+}
+
+// WithTimeout bounds the DNS lookups of one validation, the default is 5 seconds
+func WithTimeout(d time.Duration) Option {
+	return func(v *Validator) {
+		v.timeout = d
+	}
+}
+
+// WithDisposableBlocklist rejects domains of well-known disposable email
+// services and their subdomains with ErrDisposable
+func WithDisposableBlocklist() Option {
+	return func(v *Validator) {
+		v.disposable = true
+	}
+}
+
+// WithBlockedDomains rejects the given domains and their subdomains with
+// ErrBlockedDomain, domains may be written in Unicode
+func WithBlockedDomains(domains ...string) Option {
+	return func(v *Validator) {
+		if v.blocked == nil {
+			v.blocked = make(map[string]bool)
+		}
+		for _, d := range domains {
+			if ascii, err := idna.Lookup.ToASCII(d); err == nil {
+				v.blocked[ascii] = true
+			}
+		}
+	}
+}
+
+// NewValidator creates a validator with the given policies
+func NewValidator(opts ...Option) *Validator {
+	v := &Validator{timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Validate parses email and applies the configured domain policies
+func (v *Validator) Validate(ctx context.Context, email string) (Address, error) {
+	addr, err := Parse(email)
+	if err != nil || addr.IsLiteral() {
+		return addr, err
+	}
+	if err := v.checkBlocklists(addr.Domain); err != nil {
+		return Address{}, err
+	}
+	if v.resolver != nil {
+		if err := v.checkMailExchange(ctx, addr.Domain); err != nil {
+			return Address{}, err
+		}
+	}
+	return addr, nil
+}
+
+// Setting holds the Validator of a package that lets its users replace it at
+// any time, without one only the syntax is checked. The zero value is ready
+// to use and safe for concurrent use.
+type Setting struct {
+	current atomic.Pointer[Validator]
+}
+
+// Set replaces the validator, nil restores the plain syntax check
+func (s *Setting) Set(v *Validator) {
+	s.current.Store(v)
+}
+
+// Validate checks email with the validator last set, or its syntax only
+func (s *Setting) Validate(ctx context.Context, email string) error {
+	v := s.current.Load()
+	if v == nil {
+		return Validate(email)
+	}
+	_, err := v.Validate(ctx, email)
+	return err
+}
+
+// checkBlocklists matches domain and each of its parent domains against the blocklists
+func (v *Validator) checkBlocklists(domain string) error {
+	for d := domain; ; {
+		if v.blocked[d] {
+			return ErrBlockedDomain
+		}
+		if v.disposable && disposableDomains[d] {
+			return ErrDisposable
+		}
+		dot := strings.IndexByte(d, '.')
+		if dot < 0 {
+			return nil
+		}
+		d = d[dot+1:]
+	}
+}
+
+// checkMailExchange requires an MX record or, without any, an address record.
+// A single MX record with the root as host is a null MX (RFC 7505).
+func (v *Validator) checkMailExchange(ctx context.Context, domain string) error {
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+
+	records, err := v.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("lookup MX of %s: %w", domain, err)
+	}
+	if len(records) == 1 && strings.TrimSuffix(records[0].Host, ".") == "" {
+		return ErrNoMailExchange
+	}
+	if len(records) > 0 {
+		return nil
+	}
+
+	hosts, err := v.resolver.LookupHost(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("lookup host %s: %w", domain, err)
+	}
+	if len(hosts) == 0 {
+		return ErrNoMailExchange
+	}
+	return nil
+}
+
+// isNotFound reports whether err says the DNS name or record does not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
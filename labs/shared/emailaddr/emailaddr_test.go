@@ -0,0 +1,169 @@
+package emailaddr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+		err   error
+	}{
+		{"john@example.com", "john@example.com", nil},
+		{"John.Doe+tag@Mail.Example.COM", "John.Doe+tag@mail.example.com", nil},
+		{"o'brien!#$%&*=?^_`{|}~@example.org", "o'brien!#$%&*=?^_`{|}~@example.org", nil},
+		{`"john doe"@example.com`, `"john doe"@example.com`, nil},
+		{`"a@b \"c\""@example.com`, `"a@b \"c\""@example.com`, nil},
+		{"user@bücher.example", "user@xn--bcher-kva.example", nil},
+		{"user@例え.テスト", "user@xn--r8jz45g.xn--zckzah", nil},
+		{"user@[192.0.2.1]", "user@[192.0.2.1]", nil},
+		{"user@[IPv6:2001:db8::1]", "user@[IPv6:2001:db8::1]", nil},
+
+		{"", "", ErrEmpty},
+		{"johnexample.com", "", ErrInvalidFormat},
+		{"john@", "", ErrInvalidFormat},
+		{"@example.com", "", ErrInvalidFormat},
+		{"test@@example.com", "", ErrInvalidFormat},
+		{"test @example.com", "", ErrInvalidFormat},
+		{".john@example.com", "", ErrInvalidFormat},
+		{"john..doe@example.com", "", ErrInvalidFormat},
+		{"john.@example.com", "", ErrInvalidFormat},
+		{`"unterminated@example.com`, "", ErrInvalidFormat},
+		{`"bad"quote"@example.com`, "", ErrInvalidFormat},
+		{"jöhn@example.com", "", ErrInvalidFormat},
+		{"john@notvalid", "", ErrInvalidDomain},
+		{"john@example.123", "", ErrInvalidDomain},
+		{"john@example.com.", "", ErrInvalidDomain},
+		{"john@exa_mple.com", "", ErrInvalidDomain},
+		{"john@-example.com", "", ErrInvalidDomain},
+		{"john@[300.1.1.1]", "", ErrInvalidDomain},
+		{"john@[IPv6:192.0.2.1]", "", ErrInvalidDomain},
+		{"john@[192.0.2.1", "", ErrInvalidFormat},
+		{strings.Repeat("a", 65) + "@example.com", "", ErrLocalTooLong},
+		{"john@" + strings.Repeat("a", 64) + ".com", "", ErrInvalidDomain},
+		{strings.Repeat("a", 64) + "@" + strings.Repeat(strings.Repeat("b", 60)+".", 4) + "com", "", ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			addr, err := Parse(tt.email)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.email, err, tt.err)
+			}
+			if err == nil && addr.String() != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.email, addr, tt.want)
+			}
+		})
+	}
+}
+
+// stubResolver answers DNS lookups from maps, missing names are not found
+type stubResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	err   error
+}
+
+func (r stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestValidatorMailExchange(t *testing.T) {
+	resolver := stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com":           {{Host: "mx1.example.com.", Pref: 10}},
+			"nullmx.com":            {{Host: ".", Pref: 0}},
+			"xn--bcher-kva.example": {{Host: "mx.xn--bcher-kva.example.", Pref: 10}},
+		},
+		hosts: map[string][]string{"implicit.com": {"192.0.2.7"}},
+	}
+	v := NewValidator(WithResolver(resolver))
+
+	tests := []struct {
+		email string
+		err   error
+	}{
+		{"john@example.com", nil},
+		{"john@bücher.example", nil},
+		{"john@implicit.com", nil},
+		{"john@[192.0.2.1]", nil},
+		{"john@nullmx.com", ErrNoMailExchange},
+		{"john@missing.com", ErrNoMailExchange},
+		{"john@", ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		if _, err := v.Validate(context.Background(), tt.email); !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%q) error = %v, want %v", tt.email, err, tt.err)
+		}
+	}
+
+	failing := NewValidator(WithResolver(stubResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}))
+	_, err := failing.Validate(context.Background(), "john@example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || errors.Is(err, ErrNoMailExchange) {
+		t.Errorf("expected the lookup error to be returned, got %v", err)
+	}
+}
+
+func TestValidatorBlocklists(t *testing.T) {
+	v := NewValidator(WithDisposableBlocklist(), WithBlockedDomains("competitor.com", "bücher.example"))
+
+	tests := []struct {
+		email string
+		err   error
+	}{
+		{"john@example.com", nil},
+		{"john@mailinator.com", ErrDisposable},
+		{"john@eu.mailinator.com", ErrDisposable},
+		{"john@notmailinator.com", nil},
+		{"john@competitor.com", ErrBlockedDomain},
+		{"john@mail.competitor.com", ErrBlockedDomain},
+		{"john@BÜCHER.example", ErrBlockedDomain},
+	}
+	for _, tt := range tests {
+		if _, err := v.Validate(context.Background(), tt.email); !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%q) error = %v, want %v", tt.email, err, tt.err)
+		}
+	}
+
+	if _, err := NewValidator().Validate(context.Background(), "john@mailinator.com"); err != nil {
+		t.Errorf("blocklists must be opt-in, got %v", err)
+	}
+}
+
+func TestSetting(t *testing.T) {
+	var s Setting
+	ctx := context.Background()
+	if err := s.Validate(ctx, "user@example.org"); err != nil {
+		t.Errorf("expected the syntax check to pass, got %v", err)
+	}
+	if err := s.Validate(ctx, "not an address"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat, got %v", err)
+	}
+
+	s.Set(NewValidator(WithBlockedDomains("example.org")))
+	if err := s.Validate(ctx, "user@example.org"); !errors.Is(err, ErrBlockedDomain) {
+		t.Errorf("expected ErrBlockedDomain, got %v", err)
+	}
+	s.Set(nil)
+	if err := s.Validate(ctx, "user@example.org"); err != nil {
+		t.Errorf("expected the syntax check after reset, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+module shared
+
+go 1.24
+
+require (
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/net v0.41.0
+	golang.org/x/text v0.26.0
+)
@@ -0,0 +1,179 @@
+// Package personname validates and normalizes names of people. Names are
+// trimmed and converted to Unicode normalization form C, and their length is
+// counted in grapheme clusters, the characters a reader perceives, so "é"
+// written with a combining accent or a flag emoji count as one. Control and
+// invisible formatting characters are rejected, and so are name parts mixing
+// letters of scripts that are not normally written together, which is a
+// common way to spoof another user's name.
+package personname
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Validation errors
+var (
+	ErrTooShort         = errors.New("name is too short")
+	ErrTooLong          = errors.New("name is too long")
+	ErrInvalidCharacter = errors.New("name contains control or invisible characters")
+	ErrMixedScript      = errors.New("name mixes letters of unrelated scripts")
+)
+
+// Policy holds the length limits of a name in grapheme clusters, a zero
+// MaxLength means no upper limit
+type Policy struct {
+	MinLength int
+	MaxLength int
+}
+
+// Normalize trims name, converts it to NFC and validates the result, it
+// returns the normalized name to store
+func (p Policy) Normalize(name string) (string, error) {
+	name = norm.NFC.String(strings.TrimSpace(name))
+
+	if !visible(name) {
+		return "", ErrInvalidCharacter
+	}
+	n := Length(name)
+	if n < p.MinLength {
+		return "", fmt.Errorf("%w: at least %d characters", ErrTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		return "", fmt.Errorf("%w: at most %d characters", ErrTooLong, p.MaxLength)
+	}
+	for _, part := range strings.Fields(name) {
+		if !singleScript(part) {
+			return "", ErrMixedScript
+		}
+	}
+	return name, nil
+}
+
+// Validate checks name, see Normalize
+func (p Policy) Validate(name string) error {
+	_, err := p.Normalize(name)
+	return err
+}
+
+// Setting holds the Policy of a package that lets its users replace it at any
+// time, it is safe for concurrent use
+type Setting struct {
+	fallback Policy
+	current  atomic.Pointer[Policy]
+}
+
+// NewSetting returns a Setting applying fallback until Set is called
+func NewSetting(fallback Policy) *Setting {
+	return &Setting{fallback: fallback}
+}
+
+// Set replaces the policy
+func (s *Setting) Set(p Policy) {
+	s.current.Store(&p)
+}
+
+// Policy returns the policy last set, or the fallback
+func (s *Setting) Policy() Policy {
+	if p := s.current.Load(); p != nil {
+		return *p
+	}
+	return s.fallback
+}
+
+// Length returns the number of grapheme clusters in name
+func Length(name string) int {
+	return uniseg.GraphemeClusterCount(name)
+}
+
+// Zero-width joiners, which some scripts such as Persian or Devanagari need
+// inside words
+const (
+	zwnj = '\u200c'
+	zwj  = '\u200d'
+)
+
+// visible reports whether name is free of control and formatting characters,
+// joiners are allowed only between two letters
+func visible(name string) bool {
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == zwnj || r == zwj:
+			if i == 0 || i == len(runes)-1 || !isLetterOrMark(runes[i-1]) || !isLetterOrMark(runes[i+1]) {
+				return false
+			}
+		case unicode.Is(unicode.Cc, r), unicode.Is(unicode.Cf, r), r == unicode.ReplacementChar:
+			return false
+		}
+	}
+	return true
+}
+
+func isLetterOrMark(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r)
+}
+
+// allowedMixes lists scripts that are legitimately written together, following
+// the "highly restrictive" level of Unicode Technical Standard #39
+var allowedMixes = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// singleScript reports whether the letters of a name part belong to one script or
+// to one of the allowed mixes, Common and Inherited characters such as
+// digits, punctuation and combining marks go with any script
+func singleScript(name string) bool {
+	var scripts []string
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := scriptOf(r)
+		if script != "" && !slices.Contains(scripts, script) {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) <= 1 {
+		return true
+	}
+
+	for _, mix := range allowedMixes {
+		allowed := true
+		for _, script := range scripts {
+			if !slices.Contains(mix, script) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptOf returns the Unicode script of r, or "" for Common and Inherited
+func scriptOf(r rune) string {
+	if unicode.Is(unicode.Common, r) || unicode.Is(unicode.Inherited, r) {
+		return ""
+	}
+	if unicode.Is(unicode.Latin, r) {
+		return "Latin"
+	}
+	for script, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return script
+		}
+	}
+	return ""
+}
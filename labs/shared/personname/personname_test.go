@@ -0,0 +1,96 @@
+package personname
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"", 0},
+		{"John", 4},
+		{"Александра", 10},
+		{"José", 4},
+		{"Jose\u0301", 4},
+		{"👩‍👩‍👧 family", 8},
+		{"🇫🇷", 1},
+	}
+	for _, tt := range tests {
+		if got := Length(tt.name); got != tt.want {
+			t.Errorf("Length(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	policy := Policy{MinLength: 2, MaxLength: 20}
+
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"John Doe", "John Doe", nil},
+		{"  Jane Smith  ", "Jane Smith", nil},
+		{"José María", "José María", nil},
+		{"Jose\u0301", "Jos\u00e9", nil},
+		{"Александра Петрова", "Александра Петрова", nil},
+		{"Ἀλέξανδρος", "Ἀλέξανδρος", nil},
+		{"山田 太郎", "山田 太郎", nil},
+		{"やまだ タロウ 山田", "やまだ タロウ 山田", nil},
+		{"김민준", "김민준", nil},
+		{"\u0645\u06cc\u200c\u062e\u0648\u0627\u0647", "\u0645\u06cc\u200c\u062e\u0648\u0627\u0647", nil},
+		{"Mary-Jane O'Neil", "Mary-Jane O'Neil", nil},
+		{"Louis XIV 2", "Louis XIV 2", nil},
+		{"Taro 山田", "Taro 山田", nil},
+		{"Иван Smith", "Иван Smith", nil},
+		{"김 山田 たろう", "김 山田 たろう", nil},
+		{strings.Repeat("Ж", 20), strings.Repeat("Ж", 20), nil},
+
+		{"", "", ErrTooShort},
+		{"   ", "", ErrTooShort},
+		{"J", "", ErrTooShort},
+		{strings.Repeat("Ж", 21), "", ErrTooLong},
+		{"John\x00Doe", "", ErrInvalidCharacter},
+		{"John\u200bDoe", "", ErrInvalidCharacter},
+		{"John\u202eeoD", "", ErrInvalidCharacter},
+		{"John\u200d", "", ErrInvalidCharacter},
+		{"\U0001f469\u200d\U0001f467", "", ErrInvalidCharacter},
+		{"Jo\nhn", "", ErrInvalidCharacter},
+		{"P\u0430ypal", "", ErrMixedScript},
+		{"Ivanов Smith", "", ErrMixedScript},
+		{"Иван山田", "", ErrMixedScript},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := policy.Normalize(tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Normalize(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyWithoutMaximum(t *testing.T) {
+	if err := (Policy{MinLength: 1}).Validate(strings.Repeat("a", 1000)); err != nil {
+		t.Errorf("expected no upper limit, got %v", err)
+	}
+}
+
+func TestSetting(t *testing.T) {
+	s := NewSetting(Policy{MinLength: 2})
+	if err := s.Policy().Validate("J"); !errors.Is(err, ErrTooShort) {
+		t.Errorf("expected the fallback policy, got %v", err)
+	}
+	s.Set(Policy{MinLength: 1})
+	if err := s.Policy().Validate("J"); err != nil {
+		t.Errorf("expected the policy set, got %v", err)
+	}
+}